@@ -1,26 +1,77 @@
 package cmp
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/levigross/grequests"
 )
 
+const (
+	// maxThrottledRetries is how many times a request answered with 429 is retried.
+	maxThrottledRetries = 5
+	// minSlowdown and maxSlowdown bound the extra delay added between requests while CMP is overloaded.
+	minSlowdown = 500 * time.Millisecond
+	maxSlowdown = 30 * time.Second
+	// defaultLatencyThreshold is the response time above which requests are slowed down.
+	defaultLatencyThreshold = 5 * time.Second
+)
+
 type config struct {
-	Endpoint       string
-	AccessToken    string
-	MainApiContext string
-	Options        grequests.RequestOptions
+	Endpoint         string
+	AccessToken      string `sensitive:"true"`
+	MainApiContext   string
+	Options          grequests.RequestOptions
+	LatencyThreshold time.Duration
 }
 
 type Client struct {
-	config *config
+	config   *config
+	limiter  *RateLimiter
+	inflight chan struct{}
+	slowdown *slowdown
+}
+
+// Option customizes a Client created by New.
+type Option func(*Client)
+
+// WithRateLimit limits the client to rate requests per second, a rate not greater than zero means unlimited.
+func WithRateLimit(rate float64) Option {
+	return func(c *Client) {
+		c.limiter = NewRateLimiter(rate, 1)
+	}
+}
+
+// WithMaxConcurrency limits how many requests are in flight at once, zero means unlimited.
+func WithMaxConcurrency(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.inflight = make(chan struct{}, n)
+		} else {
+			c.inflight = nil
+		}
+	}
+}
+
+// WithLatencyThreshold sets the response time above which the client slows down.
+func WithLatencyThreshold(threshold time.Duration) Option {
+	return func(c *Client) {
+		c.config.LatencyThreshold = threshold
+	}
 }
 
-func New(endpoint, accessToken string) *Client {
+func New(endpoint, accessToken string, opts ...Option) *Client {
 	cmpClient := &Client{}
 	cmpClient.config = &config{}
 	cmpClient.config.Endpoint = endpoint
 	cmpClient.config.AccessToken = accessToken
 	cmpClient.config.MainApiContext = "gateway/cmp-main-api"
+	cmpClient.config.LatencyThreshold = defaultLatencyThreshold
 
 	var headers map[string]string
 	if accessToken != "" {
@@ -28,5 +79,179 @@ func New(endpoint, accessToken string) *Client {
 	}
 	cmpClient.config.Options = grequests.RequestOptions{Headers: headers, InsecureSkipVerify: true}
 
+	cmpClient.limiter = NewRateLimiter(0, 1)
+	cmpClient.slowdown = &slowdown{}
+	for _, opt := range opts {
+		opt(cmpClient)
+	}
+
 	return cmpClient
 }
+
+// ResponseError is returned for every non-2xx response.
+type ResponseError struct {
+	StatusCode int
+	Content    string
+}
+
+func (its *ResponseError) Error() string {
+	return fmt.Sprintf("[CMP] Response code: [%v]，result: [%s]", its.StatusCode, its.Content)
+}
+
+// post sends input as JSON to api under MainApiContext and returns the response body.
+func (its *Client) post(ctx context.Context, api string, input interface{}) (string, error) {
+	return its.do(ctx, http.MethodPost, api, func(options *grequests.RequestOptions) {
+		options.JSON = input
+	})
+}
+
+// Request sends an arbitrary request to path under MainApiContext with the client's authorization,
+// limits, retries and TLS settings, a non-empty body is sent as is as JSON.
+func (its *Client) Request(ctx context.Context, method, path, body string) (string, error) {
+	return its.do(ctx, strings.ToUpper(method), strings.TrimPrefix(path, "/"), func(options *grequests.RequestOptions) {
+		if body == "" {
+			return
+		}
+		headers := map[string]string{"Content-Type": "application/json"}
+		for k, v := range options.Headers {
+			headers[k] = v
+		}
+		options.Headers = headers
+		options.RequestBody = strings.NewReader(body)
+	})
+}
+
+// do sends a request to api under MainApiContext and returns the response body, body sets the
+// request body on a copy of the client options and is called again for each attempt.
+//
+// Every request goes through the rate limiter and the concurrency limit, requests answered with 429
+// are retried after backing off, and the client slows down while CMP is throttling or responding slowly.
+func (its *Client) do(ctx context.Context, method, api string, body func(*grequests.RequestOptions)) (string, error) {
+	url := fmt.Sprintf("%v/%v/%v", its.config.Endpoint, its.config.MainApiContext, api)
+
+	for attempt := 0; ; attempt++ {
+		if err := its.acquire(ctx); err != nil {
+			return "", err
+		}
+
+		options := its.config.Options
+		options.Context = ctx
+		body(&options)
+
+		start := time.Now()
+		resp, err := grequests.Req(method, url, &options)
+		its.release()
+		if err != nil {
+			return "", err
+		}
+
+		content := resp.String()
+		resp.Close()
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			its.slowdown.throttled(retryAfter(resp.Header.Get("Retry-After")))
+			if attempt < maxThrottledRetries {
+				continue
+			}
+		} else {
+			its.slowdown.observe(time.Since(start), its.config.LatencyThreshold)
+		}
+
+		if !resp.Ok {
+			return "", &ResponseError{StatusCode: resp.StatusCode, Content: content}
+		}
+		return content, nil
+	}
+}
+
+// acquire waits for the adaptive slowdown, the rate limiter and a free in-flight slot.
+func (its *Client) acquire(ctx context.Context) error {
+	if delay := its.slowdown.delay(); delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if err := its.limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	if its.inflight != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case its.inflight <- struct{}{}:
+		}
+	}
+	return nil
+}
+
+func (its *Client) release() {
+	if its.inflight != nil {
+		<-its.inflight
+	}
+}
+
+// slowdown is the extra delay added before each request while CMP is overloaded,
+// it doubles on every throttled or slow response and halves on every fast one.
+type slowdown struct {
+	mu    sync.Mutex
+	value time.Duration
+}
+
+func (its *slowdown) delay() time.Duration {
+	its.mu.Lock()
+	defer its.mu.Unlock()
+	return its.value
+}
+
+func (its *slowdown) throttled(wait time.Duration) {
+	its.mu.Lock()
+	defer its.mu.Unlock()
+
+	its.value = its.increase()
+	if wait > its.value {
+		its.value = wait
+	}
+	if its.value > maxSlowdown {
+		its.value = maxSlowdown
+	}
+}
+
+func (its *slowdown) observe(latency, threshold time.Duration) {
+	its.mu.Lock()
+	defer its.mu.Unlock()
+
+	switch {
+	case threshold > 0 && latency > threshold:
+		its.value = its.increase()
+	case its.value < minSlowdown:
+		its.value = 0
+	default:
+		its.value /= 2
+	}
+}
+
+func (its *slowdown) increase() time.Duration {
+	value := its.value * 2
+	if value < minSlowdown {
+		value = minSlowdown
+	}
+	if value > maxSlowdown {
+		value = maxSlowdown
+	}
+	return value
+}
+
+// retryAfter parses the Retry-After header given in seconds.
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -1 +1,166 @@
 package cmp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(20, 2)
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	// The burst of 2 is free, the next 2 requests wait 50ms each.
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("limiter did not wait: %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewRateLimiter(0.001, 1).Wait(ctx); err != nil {
+		t.Fatalf("first request should not wait: %s", err)
+	}
+}
+
+func TestClientRetriesThrottledRequests(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"r1","status":"success"}`))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", WithRateLimit(100), WithMaxConcurrency(1))
+	output, err := client.DescribeCommand(context.Background(), &DescribeCommandInput{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if output.Id != "r1" || atomic.LoadInt32(&requests) != 2 {
+		t.Fatalf("unexpected output %v after %d requests", output, requests)
+	}
+	if client.slowdown.delay() == 0 {
+		t.Fatal("expected the client to slow down after a 429")
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	const total = 7
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		input := &PageListInput{}
+		_ = json.NewDecoder(r.Body).Decode(input)
+
+		var rows []map[string]string
+		for i := (input.Page - 1) * input.PageSize; i < input.Page*input.PageSize && i < total; i++ {
+			rows = append(rows, map[string]string{"stepId": fmt.Sprint(i)})
+		}
+		if input.SqlId == "envelope" {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"total": total, "rows": rows})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(rows)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "")
+	for _, sqlId := range []string{"envelope", "array"} {
+		var steps []*DescribeCommandStepsOutput
+		if err := client.Paginate(context.Background(), "deploy", sqlId, nil, PaginateOptions{PageSize: 3}).All(&steps); err != nil {
+			t.Fatalf("%s: %s", sqlId, err)
+		}
+		if len(steps) != total || steps[total-1].StepId != fmt.Sprint(total-1) {
+			t.Fatalf("%s: unexpected steps %v", sqlId, steps)
+		}
+	}
+
+	it := client.Paginate(context.Background(), "deploy", "envelope", nil, PaginateOptions{PageSize: 3, MaxItems: 4})
+	defer it.Close()
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if it.Err() != nil || count != 4 || it.Total() != total {
+		t.Fatalf("unexpected iteration: %d items, total %d, err %v", count, it.Total(), it.Err())
+	}
+}
+
+func TestDescribeCommandsFullPage(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		input := &DescribeCommandsInput{}
+		_ = json.NewDecoder(r.Body).Decode(input)
+
+		var outputs []*DescribeCommandOutput
+		if input.Page == 1 {
+			for _, id := range input.Params.Ids {
+				outputs = append(outputs, &DescribeCommandOutput{Id: id})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(outputs)
+	}))
+	defer server.Close()
+
+	input := &DescribeCommandsInput{ConStr: "deploy", SqlId: "command.selectRecordByIds", Page: 1, PageSize: 3}
+	input.Params.Ids = []string{"r1", "r2", "r3"}
+	outputs, err := New(server.URL, "").DescribeCommands(context.Background(), input)
+	if err != nil || len(outputs) != 3 {
+		t.Fatalf("unexpected result: %v, %v", outputs, err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("expected a single request for a full page, got %d", n)
+	}
+}
+
+func TestCommandContentIsNotPrinted(t *testing.T) {
+	for _, v := range []fmt.Stringer{
+		CommandInput{Name: "deploy", Content: "export A='p\"w'\necho top"},
+		CommandOutput{RecordId: "r1", Content: "echo top"},
+		DescribeCommandOutput{Id: "r1", Content: "echo top"},
+	} {
+		if s := v.String(); strings.Contains(s, "top") {
+			t.Errorf("content is printed: %s", s)
+		}
+	}
+}
+
+func TestDescribeVm(t *testing.T) {
+	body := `{"id":"vm-1","status":"running","tags":[{"key":"env","value":"dev"}],"dataDisks":[{"id":"disk-1","size":100}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		input := &GetEntityInput{}
+		_ = json.NewDecoder(r.Body).Decode(input)
+		if input.SqlId != "instance.selectVm" {
+			t.Errorf("unexpected sqlId %s", input.SqlId)
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "")
+	output, err := client.DescribeVm(context.Background(), "vm-1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if output.Status != VmStatusRunning || output.Tags[0].Value != "dev" || output.DataDisks[0].Id != "disk-1" {
+		t.Fatalf("unexpected output %v", output)
+	}
+
+	body = "null"
+	if output, err := client.DescribeVm(context.Background(), "vm-1"); err != nil || output != nil {
+		t.Fatalf("expected a missing instance, got %v, %v", output, err)
+	}
+}
@@ -1,18 +1,16 @@
 package cmp
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"time"
 
-	"github.com/levigross/grequests"
-
 	"terraform-provider-bingo/utils"
 )
 
 type CommandInput struct {
 	Name        string `json:"name"`
-	Content     string `json:"content"`
+	Content     string `json:"content" sensitive:"true"`
 	HostType    string `json:"hostType"`
 	InstanceIds string `json:"instanceIds"`
 	Description string `json:"description"`
@@ -25,7 +23,7 @@ func (its CommandInput) String() string {
 type CommandOutput struct {
 	RecordId   string    `json:"recordId"`
 	Name       string    `json:"name"`
-	Content    string    `json:"content"`
+	Content    string    `json:"content" sensitive:"true"`
 	UserId     string    `json:"userId"`
 	CreateTime time.Time `json:"createTime"`
 	Status     string    `json:"status"`
@@ -38,11 +36,9 @@ func (its CommandOutput) String() string {
 }
 
 type DescribeCommandInput struct {
-	ConStr string `json:"conStr"`
-	SqlId  string `json:"sqlId"`
-	Params struct {
-		Id string `json:"id"`
-	} `json:"params"`
+	ConStr string      `json:"conStr"`
+	SqlId  string      `json:"sqlId"`
+	Params interface{} `json:"params"`
 }
 
 func (its DescribeCommandInput) String() string {
@@ -52,7 +48,7 @@ func (its DescribeCommandInput) String() string {
 type DescribeCommandOutput struct {
 	Id          string      `json:"id"`
 	Name        string      `json:"name"`
-	Content     string      `json:"content"`
+	Content     string      `json:"content" sensitive:"true"`
 	UserId      string      `json:"userId"`
 	CreateTime  time.Time   `json:"createTime"`
 	Status      string      `json:"status"`
@@ -67,6 +63,41 @@ func (its DescribeCommandOutput) String() string {
 	return utils.Prettify(its)
 }
 
+type DescribeCommandsInput struct {
+	ConStr string `json:"conStr"`
+	SqlId  string `json:"sqlId"`
+	Params struct {
+		Ids []string `json:"ids"`
+	} `json:"params"`
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+}
+
+func (its DescribeCommandsInput) String() string {
+	return utils.Prettify(its)
+}
+
+type ListCommandsInput struct {
+	ConStr   string             `json:"conStr"`
+	SqlId    string             `json:"sqlId"`
+	Params   ListCommandsParams `json:"params"`
+	Page     int                `json:"page"`
+	PageSize int                `json:"page_size"`
+}
+
+type ListCommandsParams struct {
+	UserId         string     `json:"userId,omitempty"`
+	NamePrefix     string     `json:"namePrefix,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	InstanceId     string     `json:"instanceId,omitempty"`
+	CreateTimeFrom *time.Time `json:"createTimeFrom,omitempty"`
+	CreateTimeTo   *time.Time `json:"createTimeTo,omitempty"`
+}
+
+func (its ListCommandsInput) String() string {
+	return utils.Prettify(its)
+}
+
 type DescribeCommandStepsInput struct {
 	SqlId  string `json:"sqlId"`
 	ConStr string `json:"conStr"`
@@ -102,20 +133,11 @@ func (its DescribeCommandStepsOutput) String() string {
 	return utils.Prettify(its)
 }
 
-func (its *Client) CreateCommand(input *CommandInput) (*CommandOutput, error) {
-	options := its.config.Options
-	options.JSON = input
-	resp, err := grequests.Post(fmt.Sprintf("%v/%v/api/command/sendCommand", its.config.Endpoint, its.config.MainApiContext), &options)
+func (its *Client) CreateCommand(ctx context.Context, input *CommandInput) (*CommandOutput, error) {
+	content, err := its.post(ctx, "api/command/sendCommand", input)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Close()
-
-	content := resp.String()
-	if !resp.Ok {
-		err = fmt.Errorf("[CMP] Response code: %v，result: %s", resp.StatusCode, content)
-		return nil, err
-	}
 
 	output := &CommandOutput{}
 	err = json.Unmarshal([]byte(content), &output)
@@ -123,20 +145,11 @@ func (its *Client) CreateCommand(input *CommandInput) (*CommandOutput, error) {
 	return output, err
 }
 
-func (its *Client) DescribeCommand(input *DescribeCommandInput) (*DescribeCommandOutput, error) {
-	options := its.config.Options
-	options.JSON = input
-	resp, err := grequests.Post(fmt.Sprintf("%v/%v/api/getEntity", its.config.Endpoint, its.config.MainApiContext), &options)
+func (its *Client) DescribeCommand(ctx context.Context, input *DescribeCommandInput) (*DescribeCommandOutput, error) {
+	content, err := its.post(ctx, "api/getEntity", input)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Close()
-
-	content := resp.String()
-	if !resp.Ok {
-		err = fmt.Errorf("[CMP] Response code: [%v]，result: [%s]", resp.StatusCode, content)
-		return nil, err
-	}
 
 	output := &DescribeCommandOutput{}
 	err = json.Unmarshal([]byte(content), &output)
@@ -144,24 +157,53 @@ func (its *Client) DescribeCommand(input *DescribeCommandInput) (*DescribeComman
 	return output, err
 }
 
-func (its *Client) DescribeCommandSteps(input *DescribeCommandStepsInput) ([]*DescribeCommandStepsOutput, error) {
-	options := its.config.Options
-	options.JSON = input
+// DescribeCommands queries several command records at once, records that do not exist are left out.
+// There are at most as many records as IDs, so a full page is known to be the last one.
+func (its *Client) DescribeCommands(ctx context.Context, input *DescribeCommandsInput) ([]*DescribeCommandOutput, error) {
+	var commands []*DescribeCommandOutput
+	opts := PaginateOptions{PageSize: input.PageSize, MaxItems: len(input.Params.Ids)}
+	err := its.Paginate(ctx, input.ConStr, input.SqlId, input.Params, opts).All(&commands)
 
-	resp, err := grequests.Post(fmt.Sprintf("%v/%v/api/queryPageList", its.config.Endpoint, its.config.MainApiContext), &options)
-	if err != nil {
-		return nil, err
+	return commands, err
+}
+
+// ListCommands pages through every command record matching input, input.PageSize is the page size.
+func (its *Client) ListCommands(ctx context.Context, input *ListCommandsInput) ([]*DescribeCommandOutput, error) {
+	var commands []*DescribeCommandOutput
+	err := its.Paginate(ctx, input.ConStr, input.SqlId, input.Params, PaginateOptions{PageSize: input.PageSize}).All(&commands)
+
+	return commands, err
+}
+
+// DescribeCommandSteps returns the single page input.Page of the command steps.
+func (its *Client) DescribeCommandSteps(ctx context.Context, input *DescribeCommandStepsInput) ([]*DescribeCommandStepsOutput, error) {
+	p := its.queryPage(ctx, &PageListInput{
+		ConStr:   input.ConStr,
+		SqlId:    input.SqlId,
+		Params:   input.Params,
+		Page:     input.Page,
+		PageSize: input.PageSize,
+	})
+	if p.err != nil {
+		return nil, p.err
 	}
-	defer resp.Close()
 
-	content := resp.String()
-	if !resp.Ok {
-		err = fmt.Errorf("[CMP] Response code: [%v]，result: [%s]", resp.StatusCode, content)
-		return nil, err
+	steps := make([]*DescribeCommandStepsOutput, 0, len(p.items))
+	for _, item := range p.items {
+		step := &DescribeCommandStepsOutput{}
+		if err := json.Unmarshal(item, step); err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
 	}
 
+	return steps, nil
+}
+
+// DescribeAllCommandSteps pages through every command step matching input, input.PageSize is the page size.
+func (its *Client) DescribeAllCommandSteps(ctx context.Context, input *DescribeCommandStepsInput) ([]*DescribeCommandStepsOutput, error) {
 	var steps []*DescribeCommandStepsOutput
-	err = json.Unmarshal([]byte(content), &steps)
+	err := its.Paginate(ctx, input.ConStr, input.SqlId, input.Params, PaginateOptions{PageSize: input.PageSize}).All(&steps)
 
 	return steps, err
 }
@@ -6,3 +6,27 @@ const (
 	CommandStatusSuccess   = "success"
 	CommandStatusFailed    = "failed"
 )
+
+// DefaultPageSize is the page size used when paging through queryPageList results.
+const DefaultPageSize = 100
+
+const (
+	HostTypeVirtualMachine = "1"
+	HostTypePhysical       = "2"
+)
+
+const (
+	AgentStatusOnline  = "online"
+	AgentStatusOffline = "offline"
+)
+
+const (
+	VmStatusCreating = "creating"
+	VmStatusStarting = "starting"
+	VmStatusRunning  = "running"
+	VmStatusStopping = "stopping"
+	VmStatusStopped  = "stopped"
+	VmStatusResizing = "resizing"
+	VmStatusDeleting = "deleting"
+	VmStatusError    = "error"
+)
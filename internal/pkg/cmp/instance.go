@@ -0,0 +1,82 @@
+package cmp
+
+import (
+	"context"
+	"fmt"
+
+	"terraform-provider-bingo/utils"
+)
+
+// instanceQueries maps a host type to the queries listing its instances.
+var instanceQueries = map[string]string{
+	HostTypeVirtualMachine: "instance.listVmsForAgent",
+	HostTypePhysical:       "instance.listHostsForAgent",
+}
+
+type ListInstancesInput struct {
+	HostType string
+	Params   ListInstancesParams
+	PageSize int
+}
+
+type ListInstancesParams struct {
+	Ids         []string `json:"ids,omitempty"`
+	ProjectId   string   `json:"projectId,omitempty"`
+	AgentStatus string   `json:"agentStatus,omitempty"`
+}
+
+func (its ListInstancesInput) String() string {
+	return utils.Prettify(its)
+}
+
+type InstanceTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type InstanceOutput struct {
+	Id           string         `json:"id"`
+	Name         string         `json:"name"`
+	Code         string         `json:"code"`
+	HostType     string         `json:"hostType"`
+	Ip           string         `json:"ip"`
+	ProjectId    string         `json:"projectId"`
+	OsType       string         `json:"osType"`
+	Tags         []*InstanceTag `json:"tags"`
+	AgentStatus  string         `json:"agentStatus"`
+	AgentVersion string         `json:"agentVersion"`
+}
+
+func (its InstanceOutput) String() string {
+	return utils.Prettify(its)
+}
+
+// ListInstances pages through the instances of input.HostType matching input.Params,
+// an empty host type lists virtual machines and physical hosts.
+func (its *Client) ListInstances(ctx context.Context, input *ListInstancesInput) ([]*InstanceOutput, error) {
+	hostTypes := []string{HostTypeVirtualMachine, HostTypePhysical}
+	if input.HostType != "" {
+		hostTypes = []string{input.HostType}
+	}
+
+	var instances []*InstanceOutput
+	for _, hostType := range hostTypes {
+		sqlId, ok := instanceQueries[hostType]
+		if !ok {
+			return nil, fmt.Errorf("[CMP] Unknown host type: %s", hostType)
+		}
+
+		var output []*InstanceOutput
+		if err := its.Paginate(ctx, "cmp", sqlId, input.Params, PaginateOptions{PageSize: input.PageSize}).All(&output); err != nil {
+			return nil, err
+		}
+		for _, instance := range output {
+			if instance.HostType == "" {
+				instance.HostType = hostType
+			}
+		}
+		instances = append(instances, output...)
+	}
+
+	return instances, nil
+}
@@ -0,0 +1,222 @@
+package cmp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"terraform-provider-bingo/utils"
+)
+
+// PageListInput is the body of an api/queryPageList request.
+type PageListInput struct {
+	ConStr   string      `json:"conStr"`
+	SqlId    string      `json:"sqlId"`
+	Params   interface{} `json:"params"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+}
+
+func (its PageListInput) String() string {
+	return utils.Prettify(its)
+}
+
+// PaginateOptions controls a Paginate iteration.
+type PaginateOptions struct {
+	// PageSize is the number of items requested per page, zero means DefaultPageSize.
+	PageSize int
+	// MaxItems stops the iteration after that many items, zero means unlimited.
+	MaxItems int
+}
+
+// page is one queryPageList response, total is -1 when the response is a bare array.
+type page struct {
+	items []json.RawMessage
+	total int
+	err   error
+}
+
+// Iterator walks the items of a queryPageList query, see Client.Paginate.
+type Iterator struct {
+	pages  chan *page
+	cancel context.CancelFunc
+
+	current []json.RawMessage
+	item    json.RawMessage
+	total   int
+	count   int
+	max     int
+	err     error
+}
+
+// Paginate iterates over every item returned by queryPageList for sqlId, the next page is
+// prefetched concurrently while the current one is consumed. Both bare array responses and
+// `{"total": n, "rows": [...]}` envelopes are understood.
+//
+//	it := client.Paginate(ctx, "deploy", "task.listAllStepsForAgent", params, cmp.PaginateOptions{})
+//	defer it.Close()
+//	for it.Next() {
+//		step := &cmp.DescribeCommandStepsOutput{}
+//		if err := it.Decode(step); err != nil { ... }
+//	}
+//	if err := it.Err(); err != nil { ... }
+func (its *Client) Paginate(ctx context.Context, conStr, sqlId string, params interface{}, opts PaginateOptions) *Iterator {
+	if opts.PageSize < 1 {
+		opts.PageSize = DefaultPageSize
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	it := &Iterator{
+		// One buffered page is the prefetch: the producer fetches page n+1 while page n is consumed.
+		pages:  make(chan *page, 1),
+		cancel: cancel,
+		total:  -1,
+		max:    opts.MaxItems,
+	}
+
+	go func() {
+		defer close(it.pages)
+
+		input := &PageListInput{ConStr: conStr, SqlId: sqlId, Params: params, Page: 1, PageSize: opts.PageSize}
+		fetched := 0
+		for {
+			p := its.queryPage(ctx, input)
+			select {
+			case <-ctx.Done():
+				return
+			case it.pages <- p:
+			}
+			if p.err != nil {
+				return
+			}
+
+			fetched += len(p.items)
+			if !hasMorePages(p, fetched, input.PageSize) || (opts.MaxItems > 0 && fetched >= opts.MaxItems) {
+				return
+			}
+			input.Page++
+		}
+	}()
+
+	return it
+}
+
+func hasMorePages(p *page, fetched, pageSize int) bool {
+	if len(p.items) == 0 {
+		return false
+	}
+	if p.total >= 0 {
+		return fetched < p.total
+	}
+	return len(p.items) >= pageSize
+}
+
+// Next advances to the next item, it returns false when the iteration is over or failed.
+func (it *Iterator) Next() bool {
+	if it.err != nil || (it.max > 0 && it.count >= it.max) {
+		return false
+	}
+
+	for len(it.current) == 0 {
+		p, ok := <-it.pages
+		if !ok {
+			return false
+		}
+		if p.err != nil {
+			it.err = p.err
+			return false
+		}
+		if p.total >= 0 {
+			it.total = p.total
+		}
+		if len(p.items) == 0 {
+			return false
+		}
+		it.current = p.items
+	}
+
+	it.item, it.current = it.current[0], it.current[1:]
+	it.count++
+	return true
+}
+
+// Decode unmarshals the current item into v.
+func (it *Iterator) Decode(v interface{}) error {
+	return json.Unmarshal(it.item, v)
+}
+
+// Item returns the raw JSON of the current item.
+func (it *Iterator) Item() json.RawMessage {
+	return it.item
+}
+
+// Total returns the total reported by an envelope response, or -1 when it is unknown.
+func (it *Iterator) Total() int {
+	return it.total
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close stops prefetching, it must be called when the iteration is abandoned early.
+func (it *Iterator) Close() {
+	it.cancel()
+}
+
+// All decodes every remaining item into dest, which must be a pointer to a slice.
+func (it *Iterator) All(dest interface{}) error {
+	defer it.Close()
+
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("[CMP] All requires a pointer to a slice, got %T", dest)
+	}
+	slice := v.Elem()
+	for it.Next() {
+		elem := reflect.New(slice.Type().Elem())
+		if err := it.Decode(elem.Interface()); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, elem.Elem())
+	}
+	v.Elem().Set(slice)
+
+	return it.Err()
+}
+
+// queryPage fetches one page of a queryPageList query.
+func (its *Client) queryPage(ctx context.Context, input *PageListInput) *page {
+	content, err := its.post(ctx, "api/queryPageList", input)
+	if err != nil {
+		return &page{err: err}
+	}
+	return parsePage([]byte(content))
+}
+
+func parsePage(content []byte) *page {
+	content = bytes.TrimSpace(content)
+	if len(content) > 0 && content[0] == '[' {
+		p := &page{total: -1}
+		if err := json.Unmarshal(content, &p.items); err != nil {
+			p.err = err
+		}
+		return p
+	}
+
+	envelope := struct {
+		Total *int              `json:"total"`
+		Rows  []json.RawMessage `json:"rows"`
+	}{}
+	if err := json.Unmarshal(content, &envelope); err != nil {
+		return &page{err: err}
+	}
+	p := &page{items: envelope.Rows, total: -1}
+	if envelope.Total != nil {
+		p.total = *envelope.Total
+	}
+	return p
+}
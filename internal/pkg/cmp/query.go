@@ -0,0 +1,29 @@
+package cmp
+
+import (
+	"context"
+	"encoding/json"
+
+	"terraform-provider-bingo/utils"
+)
+
+// GetEntityInput is the body of an api/getEntity request.
+type GetEntityInput struct {
+	ConStr string      `json:"conStr"`
+	SqlId  string      `json:"sqlId"`
+	Params interface{} `json:"params"`
+}
+
+func (its GetEntityInput) String() string {
+	return utils.Prettify(its)
+}
+
+// GetEntity runs a single-row query through api/getEntity and returns the raw JSON result.
+func (its *Client) GetEntity(ctx context.Context, input *GetEntityInput) (json.RawMessage, error) {
+	content, err := its.post(ctx, "api/getEntity", input)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(content), nil
+}
@@ -0,0 +1,66 @@
+package cmp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket limiting how many requests per second are sent to CMP.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter allowing rate requests per second with bursts of burst requests,
+// a rate not greater than zero means unlimited.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait blocks until a request may be sent or ctx is done.
+func (its *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		delay := its.reserve()
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token if one is available, otherwise it returns how long to wait for the next one.
+func (its *RateLimiter) reserve() time.Duration {
+	its.mu.Lock()
+	defer its.mu.Unlock()
+
+	if its.rate <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	its.tokens += now.Sub(its.last).Seconds() * its.rate
+	if its.tokens > its.burst {
+		its.tokens = its.burst
+	}
+	its.last = now
+
+	if its.tokens >= 1 {
+		its.tokens--
+		return 0
+	}
+	return time.Duration((1 - its.tokens) / its.rate * float64(time.Second))
+}
@@ -0,0 +1,118 @@
+package cmp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"terraform-provider-bingo/utils"
+)
+
+// ScriptParam is a parameter a library script accepts, it is passed to the script as an environment variable.
+type ScriptParam struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     string `json:"defaultValue"`
+	Required    bool   `json:"required"`
+}
+
+type ScriptInput struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Interpreter string         `json:"interpreter"`
+	Content     string         `json:"content"`
+	Params      []*ScriptParam `json:"params"`
+}
+
+func (its ScriptInput) String() string {
+	return utils.Prettify(its)
+}
+
+// ScriptVersionInput is the content of a new version of an existing script, versions are never modified.
+type ScriptVersionInput struct {
+	ScriptId    string         `json:"scriptId"`
+	Description string         `json:"description"`
+	Interpreter string         `json:"interpreter"`
+	Content     string         `json:"content"`
+	Params      []*ScriptParam `json:"params"`
+}
+
+func (its ScriptVersionInput) String() string {
+	return utils.Prettify(its)
+}
+
+// ScriptOutput is one version of a library script.
+type ScriptOutput struct {
+	Id          string         `json:"id"`
+	Name        string         `json:"name"`
+	Version     int            `json:"version"`
+	Description string         `json:"description"`
+	Interpreter string         `json:"interpreter"`
+	Content     string         `json:"content"`
+	Params      []*ScriptParam `json:"params"`
+	CreateTime  time.Time      `json:"createTime"`
+}
+
+func (its ScriptOutput) String() string {
+	return utils.Prettify(its)
+}
+
+// CreateScript adds a script to the script library, its content is version 1.
+func (its *Client) CreateScript(ctx context.Context, input *ScriptInput) (*ScriptOutput, error) {
+	content, err := its.post(ctx, "api/script/createScript", input)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &ScriptOutput{}
+	err = json.Unmarshal([]byte(content), &output)
+
+	return output, err
+}
+
+// CreateScriptVersion adds a version to a script and returns it, earlier versions are left as they are.
+func (its *Client) CreateScriptVersion(ctx context.Context, input *ScriptVersionInput) (*ScriptOutput, error) {
+	content, err := its.post(ctx, "api/script/createScriptVersion", input)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &ScriptOutput{}
+	err = json.Unmarshal([]byte(content), &output)
+
+	return output, err
+}
+
+// DescribeScript returns the version of the script, the latest one when version is 0,
+// or nil when the script or the version does not exist.
+func (its *Client) DescribeScript(ctx context.Context, id string, version int) (*ScriptOutput, error) {
+	content, err := its.GetEntity(ctx, &GetEntityInput{
+		ConStr: "deploy",
+		SqlId:  "script.selectScriptVersion",
+		Params: struct {
+			Id      string `json:"id"`
+			Version int    `json:"version,omitempty"`
+		}{Id: id, Version: version},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s := strings.TrimSpace(string(content)); s == "" || s == "null" || s == "{}" {
+		return nil, nil
+	}
+
+	output := &ScriptOutput{}
+	err = json.Unmarshal(content, &output)
+
+	return output, err
+}
+
+// DeleteScript removes the script and all its versions from the script library.
+func (its *Client) DeleteScript(ctx context.Context, id string) error {
+	_, err := its.post(ctx, "api/script/deleteScript", struct {
+		Id string `json:"id"`
+	}{Id: id})
+
+	return err
+}
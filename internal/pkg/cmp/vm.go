@@ -0,0 +1,140 @@
+package cmp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"terraform-provider-bingo/utils"
+)
+
+// VmDisk is a data disk of a virtual machine, Id is only known once it is created.
+type VmDisk struct {
+	Id                  string `json:"id,omitempty"`
+	Size                int    `json:"size"`
+	Type                string `json:"type,omitempty"`
+	DeleteOnTermination bool   `json:"deleteOnTermination"`
+}
+
+type VmInput struct {
+	Name             string         `json:"name"`
+	ProjectId        string         `json:"projectId,omitempty"`
+	ImageId          string         `json:"imageId"`
+	FlavorId         string         `json:"flavorId"`
+	NetworkId        string         `json:"networkId"`
+	SubnetId         string         `json:"subnetId"`
+	SecurityGroupIds []string       `json:"securityGroupIds"`
+	SystemDiskSize   int            `json:"systemDiskSize,omitempty"`
+	DataDisks        []*VmDisk      `json:"dataDisks"`
+	Tags             []*InstanceTag `json:"tags"`
+	UserData         string         `json:"userData,omitempty"`
+}
+
+func (its VmInput) String() string {
+	return utils.Prettify(its)
+}
+
+// UpdateVmInput changes the attributes of a virtual machine which do not need it to be recreated or resized.
+type UpdateVmInput struct {
+	Id               string         `json:"id"`
+	Name             string         `json:"name"`
+	SecurityGroupIds []string       `json:"securityGroupIds"`
+	Tags             []*InstanceTag `json:"tags"`
+}
+
+func (its UpdateVmInput) String() string {
+	return utils.Prettify(its)
+}
+
+type VmOutput struct {
+	Id               string         `json:"id"`
+	Name             string         `json:"name"`
+	Status           string         `json:"status"`
+	ProjectId        string         `json:"projectId"`
+	ImageId          string         `json:"imageId"`
+	FlavorId         string         `json:"flavorId"`
+	NetworkId        string         `json:"networkId"`
+	SubnetId         string         `json:"subnetId"`
+	SecurityGroupIds []string       `json:"securityGroupIds"`
+	SystemDiskSize   int            `json:"systemDiskSize"`
+	DataDisks        []*VmDisk      `json:"dataDisks"`
+	Tags             []*InstanceTag `json:"tags"`
+	Ip               string         `json:"ip"`
+	ErrorMessage     string         `json:"errorMessage"`
+}
+
+func (its VmOutput) String() string {
+	return utils.Prettify(its)
+}
+
+// CreateVm starts creating a virtual machine and returns it, it is being created until its status is running.
+func (its *Client) CreateVm(ctx context.Context, input *VmInput) (*VmOutput, error) {
+	content, err := its.post(ctx, "api/vm/createVm", input)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &VmOutput{}
+	err = json.Unmarshal([]byte(content), &output)
+
+	return output, err
+}
+
+// DescribeVm returns the virtual machine, or nil when it does not exist.
+func (its *Client) DescribeVm(ctx context.Context, id string) (*VmOutput, error) {
+	content, err := its.GetEntity(ctx, &GetEntityInput{
+		ConStr: "cmp",
+		SqlId:  "instance.selectVm",
+		Params: struct {
+			Id string `json:"id"`
+		}{Id: id},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s := strings.TrimSpace(string(content)); s == "" || s == "null" || s == "{}" {
+		return nil, nil
+	}
+
+	output := &VmOutput{}
+	err = json.Unmarshal(content, &output)
+
+	return output, err
+}
+
+func (its *Client) UpdateVm(ctx context.Context, input *UpdateVmInput) error {
+	_, err := its.post(ctx, "api/vm/updateVm", input)
+
+	return err
+}
+
+// ResizeVm starts changing the flavor of a stopped virtual machine.
+func (its *Client) ResizeVm(ctx context.Context, id, flavorId string) error {
+	_, err := its.post(ctx, "api/vm/resizeVm", struct {
+		Id       string `json:"id"`
+		FlavorId string `json:"flavorId"`
+	}{Id: id, FlavorId: flavorId})
+
+	return err
+}
+
+func (its *Client) StartVm(ctx context.Context, id string) error {
+	return its.vmAction(ctx, "api/vm/startVm", id)
+}
+
+func (its *Client) StopVm(ctx context.Context, id string) error {
+	return its.vmAction(ctx, "api/vm/stopVm", id)
+}
+
+// DeleteVm starts deleting the virtual machine and the data disks marked to be deleted with it.
+func (its *Client) DeleteVm(ctx context.Context, id string) error {
+	return its.vmAction(ctx, "api/vm/deleteVm", id)
+}
+
+func (its *Client) vmAction(ctx context.Context, api, id string) error {
+	_, err := its.post(ctx, api, struct {
+		Id string `json:"id"`
+	}{Id: id})
+
+	return err
+}
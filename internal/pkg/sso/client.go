@@ -10,9 +10,9 @@ import (
 type config struct {
 	Endpoint     string
 	ClientId     string
-	ClientSecret string
+	ClientSecret string `sensitive:"true"`
 	UserName     string
-	Password     string
+	Password     string `sensitive:"true"`
 	Options      grequests.RequestOptions
 }
 
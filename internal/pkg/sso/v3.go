@@ -11,10 +11,10 @@ import (
 )
 
 type Authorization struct {
-	AccessToken  string `json:"access_token"`
+	AccessToken  string `json:"access_token" sensitive:"true"`
 	TokenType    string `json:"token_type"`
 	ExpiresIn    int    `json:"expires_in"`
-	RefreshToken string `json:"refresh_token"`
+	RefreshToken string `json:"refresh_token" sensitive:"true"`
 }
 
 func (its Authorization) String() string {
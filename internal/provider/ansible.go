@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// playRecap is the sum of the PLAY RECAP counters of every host in a playbook run.
+type playRecap struct {
+	Ok          int
+	Changed     int
+	Unreachable int
+	Failed      int
+	Skipped     int
+	Rescued     int
+	Ignored     int
+}
+
+var playRecapLineRegexp = regexp.MustCompile(`^\s*(\S+)\s+:\s+((?:[a-z]+=[0-9]+\s*)+)$`)
+
+// parsePlayRecap parses the PLAY RECAP section of an ansible-playbook log, reporting false if it has none.
+func parsePlayRecap(log string) (*playRecap, bool) {
+	var recap *playRecap
+	scanner := bufio.NewScanner(strings.NewReader(log))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "PLAY RECAP") {
+			recap = &playRecap{}
+			continue
+		}
+		if recap == nil {
+			continue
+		}
+		m := playRecapLineRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, field := range strings.Fields(m[2]) {
+			kv := strings.SplitN(field, "=", 2)
+			n, _ := strconv.Atoi(kv[1])
+			switch kv[0] {
+			case "ok":
+				recap.Ok += n
+			case "changed":
+				recap.Changed += n
+			case "unreachable":
+				recap.Unreachable += n
+			case "failed":
+				recap.Failed += n
+			case "skipped":
+				recap.Skipped += n
+			case "rescued":
+				recap.Rescued += n
+			case "ignored":
+				recap.Ignored += n
+			}
+		}
+	}
+	return recap, recap != nil
+}
+
+// archiveDirectory packs the regular files and directories under dir into a gzipped tarball. The tarball
+// only depends on the paths, modes and contents, so the returned checksum of the tar stream is stable.
+func archiveDirectory(dir string) ([]byte, string, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is neither a regular file nor a directory", path)
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(paths)
+
+	var tarball bytes.Buffer
+	tw := tar.NewWriter(&tarball)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, "", err
+		}
+		header := &tar.Header{
+			Name:    filepath.ToSlash(name),
+			Mode:    int64(info.Mode().Perm()),
+			ModTime: time.Unix(0, 0),
+			Format:  tar.FormatPAX,
+		}
+		if info.IsDir() {
+			header.Typeflag, header.Name = tar.TypeDir, header.Name+"/"
+			if err := tw.WriteHeader(header); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		header.Typeflag, header.Size = tar.TypeReg, int64(len(content))
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, "", err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, "", err
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(tarball.Bytes()); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+	return compressed.Bytes(), sha256Hex(tarball.Bytes()), nil
+}
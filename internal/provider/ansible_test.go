@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePlayRecap(t *testing.T) {
+	log := `PLAY [all] *********************************************************************
+
+TASK [nginx : install] *********************************************************
+changed: [localhost]
+
+PLAY RECAP *********************************************************************
+localhost                  : ok=5    changed=2    unreachable=0    failed=1    skipped=3    rescued=0    ignored=1
+`
+	recap, ok := parsePlayRecap(log)
+	if !ok {
+		t.Fatalf("expected a recap")
+	}
+	want := &playRecap{Ok: 5, Changed: 2, Failed: 1, Skipped: 3, Ignored: 1}
+	if !reflect.DeepEqual(recap, want) {
+		t.Errorf("parsePlayRecap() = %+v, want %+v", recap, want)
+	}
+
+	if _, ok := parsePlayRecap("ERROR! the playbook: site.yml could not be found"); ok {
+		t.Errorf("expected no recap")
+	}
+}
+
+func TestArchiveDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "roles", "nginx", "tasks"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "site.yml"), []byte("- hosts: all\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "roles", "nginx", "tasks", "main.yml"), []byte("- ping:\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, hash, err := archiveDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Touching a file does not change the hash, editing it does.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "site.yml"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, again, _ := archiveDirectory(dir); again != hash {
+		t.Errorf("hash changed with the modification time")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "site.yml"), []byte("- hosts: localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, again, _ := archiveDirectory(dir); again == hash {
+		t.Errorf("hash did not change with the content")
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	want := []string{"roles/", "roles/nginx/", "roles/nginx/tasks/", "roles/nginx/tasks/main.yml", "site.yml"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("archived %v, want %v", names, want)
+	}
+}
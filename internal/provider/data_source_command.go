@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+	"terraform-provider-bingo/utils"
+)
+
+func dataSourceCmpCommand() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "查询已下发的CMP指令",
+
+		ReadContext: dataSourceCmpCommandRead,
+
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(30 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"record_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"record_id", "task_id"},
+				Description:  "记录ID",
+			},
+			"task_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "任务ID",
+			},
+			"wait_for_completion": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "是否等待指令执行结束（成功或失败）",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "指令名称",
+			},
+			"content": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "命令内容",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "指令状态",
+			},
+			"machines": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "执行指令的机器",
+			},
+			"user_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "下发指令的用户ID",
+			},
+			"create_time": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "创建时间",
+			},
+			"start_time": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "开始时间",
+			},
+			"end_time": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "结束时间",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "指令描述",
+			},
+		},
+	}
+}
+
+func dataSourceCmpCommandRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	input := &cmp.DescribeCommandInput{ConStr: "deploy"}
+	if v, ok := d.GetOk("record_id"); ok {
+		input.SqlId = "command.selectRecordById"
+		input.Params = struct {
+			Id string `json:"id"`
+		}{Id: v.(string)}
+	} else {
+		input.SqlId = "command.selectRecordByTaskId"
+		input.Params = struct {
+			TaskId string `json:"taskId"`
+		}{TaskId: d.Get("task_id").(string)}
+	}
+
+	output, err := client.cmpClient.DescribeCommand(ctx, input)
+	if err != nil {
+		return diagErrorf("[CMP] Unable to read command, got error: %s", err)
+	}
+	if output == nil || output.Id == "" {
+		return diagErrorf("[CMP] Command not found: %s", input)
+	}
+
+	if d.Get("wait_for_completion").(bool) {
+		stateConf := &resource.StateChangeConf{
+			Pending:      []string{cmp.CommandStatusNew, cmp.CommandStatusDeploying},
+			Target:       []string{cmp.CommandStatusSuccess, cmp.CommandStatusFailed},
+			Refresh:      refreshCommandRecord(ctx, client, output.Id),
+			Timeout:      d.Timeout(schema.TimeoutRead),
+			PollInterval: 20 * time.Second,
+		}
+
+		result, err := stateConf.WaitForStateContext(ctx)
+		if err != nil {
+			return diagErrorf("[CMP] Waiting for command (%s) : %s", output.Id, err)
+		}
+		output = result.(*cmp.DescribeCommandOutput)
+	}
+
+	d.SetId(output.Id)
+	d.Set("record_id", output.Id)
+	d.Set("task_id", output.TaskId)
+	d.Set("name", output.Name)
+	d.Set("content", utils.Redact(output.Content))
+	d.Set("status", output.Status)
+	d.Set("machines", output.Machines)
+	d.Set("user_id", output.UserId)
+	d.Set("create_time", formatTime(output.CreateTime))
+	d.Set("start_time", formatTime(output.StartTime))
+	d.Set("end_time", formatTime(output.EndTime))
+	if output.Description != nil {
+		d.Set("description", fmt.Sprint(output.Description))
+	} else {
+		d.Set("description", "")
+	}
+
+	tflog.Debug(ctx, "[CMP] Read a command successfully", utils.RedactFields(map[string]interface{}{
+		"input":  input,
+		"output": output,
+	}))
+
+	return nil
+}
+
+// refreshCommandRecord reports the status of the command record as is, a failed command is not an error.
+func refreshCommandRecord(ctx context.Context, client *bingoCloudClient, recordId string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		output, err := client.poller.Describe(ctx, recordId)
+		if err != nil {
+			return nil, "", err
+		}
+		return output, output.Status, nil
+	}
+}
+
+// formatTime formats t as RFC 3339, the zero time is empty.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
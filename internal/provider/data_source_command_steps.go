@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+	"terraform-provider-bingo/utils"
+)
+
+func dataSourceCmpCommandSteps() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "查询CMP指令在各机器上的执行步骤",
+
+		ReadContext: dataSourceCmpCommandStepsRead,
+
+		Schema: map[string]*schema.Schema{
+			"task_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "任务ID",
+			},
+			"machine": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "按机器过滤，匹配机器ID、名称、编码或实例编码",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "按步骤状态过滤",
+			},
+			"log_tail_lines": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "仅保留日志的最后N行，0表示不截断",
+			},
+			"log_max_bytes": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "仅保留日志的最后N个字节，0表示不截断",
+			},
+			"steps": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "执行步骤",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"step_id":       {Type: schema.TypeString, Computed: true, Description: "步骤ID"},
+						"machine_id":    {Type: schema.TypeString, Computed: true, Description: "机器ID"},
+						"machine_name":  {Type: schema.TypeString, Computed: true, Description: "机器名称"},
+						"machine_code":  {Type: schema.TypeString, Computed: true, Description: "机器编码"},
+						"instance_code": {Type: schema.TypeString, Computed: true, Description: "实例编码"},
+						"agent":         {Type: schema.TypeString, Computed: true, Description: "代理"},
+						"step_content":  {Type: schema.TypeString, Computed: true, Description: "步骤内容"},
+						"step_desc":     {Type: schema.TypeString, Computed: true, Description: "步骤描述"},
+						"step_status":   {Type: schema.TypeString, Computed: true, Description: "步骤状态"},
+						"progress":      {Type: schema.TypeString, Computed: true, Description: "执行进度"},
+						"step_log":      {Type: schema.TypeString, Computed: true, Description: "执行日志"},
+						"log_truncated": {Type: schema.TypeBool, Computed: true, Description: "执行日志是否被截断"},
+						"create_time":   {Type: schema.TypeString, Computed: true, Description: "创建时间"},
+						"start_time":    {Type: schema.TypeString, Computed: true, Description: "开始时间"},
+						"end_time":      {Type: schema.TypeString, Computed: true, Description: "结束时间"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceCmpCommandStepsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	taskId := d.Get("task_id").(string)
+	input := &cmp.DescribeCommandStepsInput{
+		SqlId:  "task.listAllStepsForAgent",
+		ConStr: "deploy",
+		Params: struct {
+			TaskId string `json:"taskId"`
+		}{TaskId: taskId},
+	}
+
+	output, err := client.cmpClient.DescribeAllCommandSteps(ctx, input)
+	if err != nil {
+		return diagErrorf("[CMP] Unable to read command steps, got error: %s", err)
+	}
+
+	machine := d.Get("machine").(string)
+	status := d.Get("status").(string)
+	tailLines := d.Get("log_tail_lines").(int)
+	maxBytes := d.Get("log_max_bytes").(int)
+
+	steps := make([]interface{}, 0, len(output))
+	for _, step := range output {
+		if machine != "" && !stepMatchesMachine(step, machine) {
+			continue
+		}
+		if status != "" && step.StepStatus != status {
+			continue
+		}
+
+		log, truncated := tailLog(utils.Redact(step.StepLog), tailLines, maxBytes)
+		steps = append(steps, map[string]interface{}{
+			"step_id":       step.StepId,
+			"machine_id":    step.MachineId,
+			"machine_name":  step.MachineName,
+			"machine_code":  step.MachineCode,
+			"instance_code": step.InstanceCode,
+			"agent":         step.Agent,
+			"step_content":  utils.Redact(step.StepContent),
+			"step_desc":     step.StepDesc,
+			"step_status":   step.StepStatus,
+			"progress":      step.Progress,
+			"step_log":      log,
+			"log_truncated": truncated,
+			"create_time":   formatTime(step.CreateTime),
+			"start_time":    formatTime(step.StartTime),
+			"end_time":      formatTime(step.EndTime),
+		})
+	}
+
+	d.SetId(taskId)
+	if err := d.Set("steps", steps); err != nil {
+		return diagErrorf("[CMP] Unable to set command steps: %s", err)
+	}
+
+	tflog.Debug(ctx, "[CMP] Read command steps successfully", map[string]interface{}{
+		"task_id": taskId,
+		"total":   len(output),
+		"matched": len(steps),
+	})
+
+	return nil
+}
+
+func stepMatchesMachine(step *cmp.DescribeCommandStepsOutput, machine string) bool {
+	for _, v := range []string{step.MachineId, step.MachineName, step.MachineCode, step.InstanceCode} {
+		if v == machine {
+			return true
+		}
+	}
+	return false
+}
+
+// tailLog keeps the last lines lines and the last maxBytes bytes of log, zero means unlimited.
+func tailLog(log string, lines, maxBytes int) (string, bool) {
+	truncated := false
+
+	if lines > 0 {
+		parts := strings.Split(strings.TrimRight(log, "\n"), "\n")
+		if len(parts) > lines {
+			log = strings.Join(parts[len(parts)-lines:], "\n")
+			truncated = true
+		}
+	}
+
+	if maxBytes > 0 && len(log) > maxBytes {
+		log = log[len(log)-maxBytes:]
+		// Do not start in the middle of a multi-byte character.
+		for len(log) > 0 && !utf8.RuneStart(log[0]) {
+			log = log[1:]
+		}
+		truncated = true
+	}
+
+	return log, truncated
+}
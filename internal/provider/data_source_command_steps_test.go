@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestCommandStepsDataSource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testCommandStepsDataSourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.bingo_cmp_command_steps.dev", "steps.#", "1"),
+					resource.TestCheckResourceAttr("data.bingo_cmp_command_steps.dev", "steps.0.step_status", "success"),
+				),
+			},
+		},
+	})
+}
+
+func TestTailLog(t *testing.T) {
+	cases := []struct {
+		log, want     string
+		lines, bytes  int
+		wantTruncated bool
+	}{
+		{"a\nb\nc\n", "a\nb\nc\n", 0, 0, false},
+		{"a\nb\nc\n", "b\nc", 2, 0, true},
+		{"abcdef", "def", 0, 3, true},
+		{"日志", "志", 0, 4, true},
+	}
+	for _, c := range cases {
+		got, truncated := tailLog(c.log, c.lines, c.bytes)
+		if got != c.want || truncated != c.wantTruncated {
+			t.Errorf("tailLog(%q, %d, %d) = %q, %v", c.log, c.lines, c.bytes, got, truncated)
+		}
+	}
+}
+
+func testCommandStepsDataSourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_command" "dev" {
+  host_type   	= "1" 
+  content     	= "pwd"
+  instance_ids	= "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+}
+
+data "bingo_cmp_command_steps" "dev" {
+  task_id        = bingo_cmp_command.dev.task_id
+  machine        = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  log_tail_lines = 20
+}
+`)
+}
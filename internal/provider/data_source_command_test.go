@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestCommandDataSource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testCommandDataSourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("data.bingo_cmp_command.dev", "record_id", "bingo_cmp_command.dev", "record_id"),
+					resource.TestCheckResourceAttrPair("data.bingo_cmp_command.dev", "task_id", "bingo_cmp_command.dev", "task_id"),
+					resource.TestCheckResourceAttr("data.bingo_cmp_command.dev", "content", "pwd"),
+					resource.TestCheckResourceAttr("data.bingo_cmp_command.dev", "status", "success"),
+				),
+			},
+		},
+	})
+}
+
+func testCommandDataSourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_command" "dev" {
+  host_type   	= "1" 
+  content     	= "pwd"
+  instance_ids	= "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+}
+
+data "bingo_cmp_command" "dev" {
+  task_id             = bingo_cmp_command.dev.task_id
+  wait_for_completion = true
+}
+`)
+}
@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+	"terraform-provider-bingo/utils"
+)
+
+func dataSourceCmpCommands() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "按用户、名称、状态、实例及创建时间查询CMP指令历史",
+
+		ReadContext: dataSourceCmpCommandsRead,
+
+		Schema: map[string]*schema.Schema{
+			"user_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "下发指令的用户ID",
+			},
+			"name_prefix": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "指令名称前缀",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "指令状态",
+			},
+			"instance_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "执行指令的实例编号",
+			},
+			"created_after": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsRFC3339Time,
+				Description:  "创建时间下限（含），RFC 3339格式",
+			},
+			"created_before": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsRFC3339Time,
+				Description:  "创建时间上限（不含），RFC 3339格式",
+			},
+			"ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "记录ID列表",
+			},
+			"commands": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "指令记录",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"record_id":   {Type: schema.TypeString, Computed: true, Description: "记录ID"},
+						"task_id":     {Type: schema.TypeString, Computed: true, Description: "任务ID"},
+						"name":        {Type: schema.TypeString, Computed: true, Description: "指令名称"},
+						"content":     {Type: schema.TypeString, Computed: true, Description: "命令内容"},
+						"status":      {Type: schema.TypeString, Computed: true, Description: "指令状态"},
+						"machines":    {Type: schema.TypeString, Computed: true, Description: "执行指令的机器"},
+						"user_id":     {Type: schema.TypeString, Computed: true, Description: "下发指令的用户ID"},
+						"create_time": {Type: schema.TypeString, Computed: true, Description: "创建时间"},
+						"start_time":  {Type: schema.TypeString, Computed: true, Description: "开始时间"},
+						"end_time":    {Type: schema.TypeString, Computed: true, Description: "结束时间"},
+						"description": {Type: schema.TypeString, Computed: true, Description: "指令描述"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceCmpCommandsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	input := &cmp.ListCommandsInput{
+		ConStr: "deploy",
+		SqlId:  "command.listRecords",
+		Params: cmp.ListCommandsParams{
+			UserId:     d.Get("user_id").(string),
+			NamePrefix: d.Get("name_prefix").(string),
+			Status:     d.Get("status").(string),
+			InstanceId: d.Get("instance_id").(string),
+		},
+	}
+	if v, ok := d.GetOk("created_after"); ok {
+		t, _ := time.Parse(time.RFC3339, v.(string))
+		input.Params.CreateTimeFrom = &t
+	}
+	if v, ok := d.GetOk("created_before"); ok {
+		t, _ := time.Parse(time.RFC3339, v.(string))
+		input.Params.CreateTimeTo = &t
+	}
+
+	output, err := client.cmpClient.ListCommands(ctx, input)
+	if err != nil {
+		return diagErrorf("[CMP] Unable to list commands, got error: %s", err)
+	}
+
+	ids := make([]string, 0, len(output))
+	commands := make([]interface{}, 0, len(output))
+	for _, command := range output {
+		// Filter again locally, so the result never depends on how strictly the query applies the params.
+		// The instance is left to the query, `machines` may hold host names or IPs rather than instance IDs.
+		if !commandMatches(command, &input.Params) {
+			continue
+		}
+		ids = append(ids, command.Id)
+		commands = append(commands, flattenCommandRecord(command))
+	}
+
+	d.SetId(strconv.Itoa(schema.HashString(utils.PrettifyJSON(input.Params))))
+	d.Set("ids", ids)
+	if err := d.Set("commands", commands); err != nil {
+		return diagErrorf("[CMP] Unable to set commands: %s", err)
+	}
+
+	tflog.Debug(ctx, "[CMP] Listed commands successfully", utils.RedactFields(map[string]interface{}{
+		"input":   input,
+		"matched": len(commands),
+	}))
+
+	return nil
+}
+
+func commandMatches(command *cmp.DescribeCommandOutput, params *cmp.ListCommandsParams) bool {
+	if params.UserId != "" && command.UserId != params.UserId {
+		return false
+	}
+	if params.NamePrefix != "" && !strings.HasPrefix(command.Name, params.NamePrefix) {
+		return false
+	}
+	if params.Status != "" && command.Status != params.Status {
+		return false
+	}
+	if params.CreateTimeFrom != nil && command.CreateTime.Before(*params.CreateTimeFrom) {
+		return false
+	}
+	if params.CreateTimeTo != nil && !command.CreateTime.Before(*params.CreateTimeTo) {
+		return false
+	}
+	return true
+}
+
+func flattenCommandRecord(command *cmp.DescribeCommandOutput) map[string]interface{} {
+	description := ""
+	if command.Description != nil {
+		description = fmt.Sprint(command.Description)
+	}
+
+	return map[string]interface{}{
+		"record_id":   command.Id,
+		"task_id":     command.TaskId,
+		"name":        command.Name,
+		"content":     utils.Redact(command.Content),
+		"status":      command.Status,
+		"machines":    command.Machines,
+		"user_id":     command.UserId,
+		"create_time": formatTime(command.CreateTime),
+		"start_time":  formatTime(command.StartTime),
+		"end_time":    formatTime(command.EndTime),
+		"description": description,
+	}
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+func TestCommandMatches(t *testing.T) {
+	command := &cmp.DescribeCommandOutput{Name: "terraform-deploy-1", Status: cmp.CommandStatusSuccess, Machines: "web-1,10.0.0.12"}
+	if !commandMatches(command, &cmp.ListCommandsParams{NamePrefix: "terraform-", InstanceId: "i-1"}) {
+		t.Fatal("expected the instance to be left to the query")
+	}
+	if commandMatches(command, &cmp.ListCommandsParams{Status: cmp.CommandStatusFailed}) {
+		t.Fatal("expected a status mismatch")
+	}
+}
+
+func TestCommandsDataSource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testCommandsDataSourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("data.bingo_cmp_commands.dev", "ids.0"),
+					resource.TestCheckResourceAttr("data.bingo_cmp_commands.dev", "commands.0.status", "success"),
+				),
+			},
+		},
+	})
+}
+
+func testCommandsDataSourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+data "bingo_cmp_commands" "dev" {
+  name_prefix   = "terraform-deploy-"
+  status        = "success"
+  instance_id   = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  created_after = timeadd(timestamp(), "-24h")
+}
+`)
+}
@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+func dataSourceCmpHostFacts() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "通过CMP指令采集实例的操作系统、内核、CPU、内存及软件包版本等信息，" +
+			"按实例的操作系统选择Linux Shell或Windows PowerShell脚本，同一次计划或应用中的结果会被缓存",
+
+		ReadContext: dataSourceCmpHostFactsRead,
+
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(10 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"host_type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.HostTypeVirtualMachine, cmp.HostTypePhysical}, false),
+				Description:  "宿主机类型，1:虚拟机,2:物理机",
+			},
+			"instance_ids": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "实例编号，多个用逗号分割",
+			},
+			"packages": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringMatch(packageNameRegexp, "must be a package name"),
+				},
+				Description: "需要查询版本的软件包，Linux为rpm或deb包名，Windows为程序名称",
+			},
+			"hosts": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "各实例的信息，与`instance_ids`顺序一致",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"instance_id":    {Type: schema.TypeString, Computed: true, Description: "实例编号"},
+						"hostname":       {Type: schema.TypeString, Computed: true, Description: "主机名"},
+						"os_family":      {Type: schema.TypeString, Computed: true, Description: "操作系统类别，linux或windows"},
+						"distribution":   {Type: schema.TypeString, Computed: true, Description: "发行版，如centos、ubuntu"},
+						"os_name":        {Type: schema.TypeString, Computed: true, Description: "操作系统名称"},
+						"os_version":     {Type: schema.TypeString, Computed: true, Description: "操作系统版本"},
+						"kernel_version": {Type: schema.TypeString, Computed: true, Description: "内核版本，Windows为内部版本号"},
+						"architecture":   {Type: schema.TypeString, Computed: true, Description: "CPU架构"},
+						"cpu_count":      {Type: schema.TypeInt, Computed: true, Description: "逻辑CPU数"},
+						"memory_mb":      {Type: schema.TypeInt, Computed: true, Description: "内存大小（MB）"},
+						"packages": {
+							Type:        schema.TypeMap,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "软件包版本，未安装时为空",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceCmpHostFactsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	hostType := d.Get("host_type").(string)
+	instanceIds := splitInstanceIds(d.Get("instance_ids").(string))
+	var packages []string
+	for _, v := range d.Get("packages").([]interface{}) {
+		packages = append(packages, v.(string))
+	}
+
+	sorted := append([]string(nil), packages...)
+	sort.Strings(sorted)
+	prefix := hostType + "|" + strings.Join(sorted, ",") + "|"
+
+	facts, err := client.facts.Get(ctx, prefix, instanceIds, func(ids []string) (map[string]*hostFacts, error) {
+		return gatherHostFacts(ctx, client, hostType, ids, packages, d.Timeout(schema.TimeoutRead))
+	})
+	if err != nil {
+		return diagErrorf("[CMP] Unable to gather host facts, got error: %s", err)
+	}
+
+	hosts := make([]interface{}, 0, len(instanceIds))
+	for _, id := range instanceIds {
+		hosts = append(hosts, flattenHostFacts(id, facts[id]))
+	}
+
+	d.SetId(strconv.Itoa(schema.HashString(prefix + strings.Join(instanceIds, ","))))
+	if err := d.Set("hosts", hosts); err != nil {
+		return diagErrorf("[CMP] Unable to set hosts: %s", err)
+	}
+
+	return nil
+}
+
+// gatherHostFacts runs the fact-gathering script matching the OS of each instance.
+func gatherHostFacts(ctx context.Context, client *bingoCloudClient, hostType string, instanceIds, packages []string, timeout time.Duration) (map[string]*hostFacts, error) {
+	groups, err := groupInstancesByOsFamily(ctx, client, hostType, instanceIds)
+	if err != nil {
+		return nil, err
+	}
+
+	facts := map[string]*hostFacts{}
+	for family, ids := range groups {
+		script := linuxFactsScript(packages)
+		if family == osFamilyWindows {
+			script = windowsFactsScript(packages)
+		}
+		results, err := runCommand(ctx, client, &commandRun{
+			HostType:    hostType,
+			InstanceIds: ids,
+			Name:        "terraform-host-facts",
+			Content:     script,
+			Timeout:     timeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := failedResults(results); err != nil {
+			return nil, err
+		}
+		for id, result := range results {
+			f, err := parseHostFacts(result)
+			if err != nil {
+				return nil, fmt.Errorf("instance %s: %s", id, err)
+			}
+			facts[id] = f
+		}
+	}
+
+	tflog.Debug(ctx, "[CMP] Gathered host facts successfully", map[string]interface{}{
+		"instances": len(facts),
+	})
+
+	return facts, nil
+}
+
+func flattenHostFacts(instanceId string, facts *hostFacts) map[string]interface{} {
+	packages := map[string]interface{}{}
+	for k, v := range facts.Packages {
+		packages[k] = v
+	}
+	return map[string]interface{}{
+		"instance_id":    instanceId,
+		"hostname":       facts.Hostname,
+		"os_family":      facts.OsFamily,
+		"distribution":   facts.Distribution,
+		"os_name":        facts.OsName,
+		"os_version":     facts.OsVersion,
+		"kernel_version": facts.KernelVersion,
+		"architecture":   facts.Architecture,
+		"cpu_count":      facts.CpuCount,
+		"memory_mb":      facts.MemoryMb,
+		"packages":       packages,
+	}
+}
@@ -0,0 +1,38 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestHostFactsDataSource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testHostFactsDataSourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.bingo_cmp_host_facts.dev", "hosts.0.os_family", "linux"),
+					resource.TestCheckResourceAttrSet("data.bingo_cmp_host_facts.dev", "hosts.0.kernel_version"),
+				),
+			},
+		},
+	})
+}
+
+func testHostFactsDataSourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+data "bingo_cmp_host_facts" "dev" {
+  host_type    = "1"
+  instance_ids = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  packages     = ["openssh-server"]
+}
+`)
+}
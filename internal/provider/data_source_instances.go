@@ -0,0 +1,233 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+	"terraform-provider-bingo/utils"
+)
+
+func dataSourceCmpInstances() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "按名称、标签、IP段、宿主机类型、项目及代理状态查询虚拟机和物理机",
+
+		ReadContext: dataSourceCmpInstancesRead,
+
+		Schema: map[string]*schema.Schema{
+			"name_regex": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsValidRegExp,
+				Description:  "实例名称的正则表达式",
+			},
+			"tags": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "实例须包含的全部标签",
+			},
+			"ip_cidr": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsCIDR,
+				Description:  "实例IP所在网段，如`10.0.0.0/24`",
+			},
+			"host_type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.HostTypeVirtualMachine, cmp.HostTypePhysical}, false),
+				Description:  "宿主机类型，1:虚拟机,2:物理机，为空时查询全部",
+			},
+			"project_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "项目ID",
+			},
+			"agent_status": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.AgentStatusOnline, cmp.AgentStatusOffline}, false),
+				Description:  "代理状态，online:在线,offline:离线",
+			},
+			"ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "实例编号列表，`host_type`为空时包含虚拟机和物理机",
+			},
+			"instance_ids": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "逗号分割的实例编号，`host_type`不为空时可直接用于`bingo_cmp_command`的`instance_ids`",
+			},
+			"vm_instance_ids": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "逗号分割的虚拟机实例编号，可直接用于`host_type`为1的`bingo_cmp_command`",
+			},
+			"physical_instance_ids": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "逗号分割的物理机实例编号，可直接用于`host_type`为2的`bingo_cmp_command`",
+			},
+			"instances": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "实例列表",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id":            {Type: schema.TypeString, Computed: true, Description: "实例编号"},
+						"name":          {Type: schema.TypeString, Computed: true, Description: "实例名称"},
+						"code":          {Type: schema.TypeString, Computed: true, Description: "实例编码"},
+						"host_type":     {Type: schema.TypeString, Computed: true, Description: "宿主机类型"},
+						"ip":            {Type: schema.TypeString, Computed: true, Description: "IP地址"},
+						"project_id":    {Type: schema.TypeString, Computed: true, Description: "项目ID"},
+						"os_type":       {Type: schema.TypeString, Computed: true, Description: "操作系统类型"},
+						"agent_status":  {Type: schema.TypeString, Computed: true, Description: "代理状态"},
+						"agent_version": {Type: schema.TypeString, Computed: true, Description: "代理版本"},
+						"tags": {
+							Type:        schema.TypeMap,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "标签",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceCmpInstancesRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	input := &cmp.ListInstancesInput{
+		HostType: d.Get("host_type").(string),
+		Params: cmp.ListInstancesParams{
+			ProjectId:   d.Get("project_id").(string),
+			AgentStatus: d.Get("agent_status").(string),
+		},
+	}
+
+	output, err := client.cmpClient.ListInstances(ctx, input)
+	if err != nil {
+		return diagErrorf("[CMP] Unable to list instances, got error: %s", err)
+	}
+
+	filter := &instanceFilter{
+		tags:        d.Get("tags").(map[string]interface{}),
+		projectId:   input.Params.ProjectId,
+		agentStatus: input.Params.AgentStatus,
+	}
+	if v, ok := d.GetOk("name_regex"); ok {
+		filter.nameRegex = regexp.MustCompile(v.(string))
+	}
+	if v, ok := d.GetOk("ip_cidr"); ok {
+		_, filter.ipNet, _ = net.ParseCIDR(v.(string))
+	}
+
+	ids := make([]string, 0, len(output))
+	// A command targets a single host type, so the IDs are also split by host type.
+	idsByHostType := map[string][]string{}
+	instances := make([]interface{}, 0, len(output))
+	for _, instance := range output {
+		if !filter.matches(instance) {
+			continue
+		}
+		ids = append(ids, instance.Id)
+		idsByHostType[instance.HostType] = append(idsByHostType[instance.HostType], instance.Id)
+		instances = append(instances, flattenInstance(instance))
+	}
+
+	d.SetId(strconv.Itoa(schema.HashString(strings.Join(ids, ","))))
+	d.Set("ids", ids)
+	d.Set("instance_ids", strings.Join(ids, ","))
+	d.Set("vm_instance_ids", strings.Join(idsByHostType[cmp.HostTypeVirtualMachine], ","))
+	d.Set("physical_instance_ids", strings.Join(idsByHostType[cmp.HostTypePhysical], ","))
+	if err := d.Set("instances", instances); err != nil {
+		return diagErrorf("[CMP] Unable to set instances: %s", err)
+	}
+
+	tflog.Debug(ctx, "[CMP] Listed instances successfully", utils.RedactFields(map[string]interface{}{
+		"input":   input,
+		"total":   len(output),
+		"matched": len(ids),
+	}))
+
+	return nil
+}
+
+// instanceFilter applies the filters locally, the query params only narrow down what is fetched.
+type instanceFilter struct {
+	nameRegex   *regexp.Regexp
+	tags        map[string]interface{}
+	ipNet       *net.IPNet
+	projectId   string
+	agentStatus string
+}
+
+func (its *instanceFilter) matches(instance *cmp.InstanceOutput) bool {
+	if its.nameRegex != nil && !its.nameRegex.MatchString(instance.Name) {
+		return false
+	}
+	if its.projectId != "" && instance.ProjectId != its.projectId {
+		return false
+	}
+	if its.agentStatus != "" && instance.AgentStatus != its.agentStatus {
+		return false
+	}
+	if its.ipNet != nil && !instanceInNetwork(instance, its.ipNet) {
+		return false
+	}
+
+	tags := instanceTags(instance)
+	for k, v := range its.tags {
+		if value, ok := tags[k]; !ok || value != v.(string) {
+			return false
+		}
+	}
+	return true
+}
+
+// instanceInNetwork reports whether any of the comma separated IPs of the instance is in ipNet.
+func instanceInNetwork(instance *cmp.InstanceOutput, ipNet *net.IPNet) bool {
+	for _, s := range strings.Split(instance.Ip, ",") {
+		if ip := net.ParseIP(strings.TrimSpace(s)); ip != nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func instanceTags(instance *cmp.InstanceOutput) map[string]interface{} {
+	tags := make(map[string]interface{}, len(instance.Tags))
+	for _, tag := range instance.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags
+}
+
+func flattenInstance(instance *cmp.InstanceOutput) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            instance.Id,
+		"name":          instance.Name,
+		"code":          instance.Code,
+		"host_type":     instance.HostType,
+		"ip":            instance.Ip,
+		"project_id":    instance.ProjectId,
+		"os_type":       instance.OsType,
+		"agent_status":  instance.AgentStatus,
+		"agent_version": instance.AgentVersion,
+		"tags":          instanceTags(instance),
+	}
+}
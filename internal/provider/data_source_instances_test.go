@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+func TestInstancesDataSource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testInstancesDataSourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("data.bingo_cmp_instances.dev", "ids.0"),
+					resource.TestCheckResourceAttr("data.bingo_cmp_instances.dev", "instances.0.agent_status", "online"),
+				),
+			},
+		},
+	})
+}
+
+func TestInstanceFilter(t *testing.T) {
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/24")
+	filter := &instanceFilter{
+		nameRegex:   regexp.MustCompile("^web-"),
+		tags:        map[string]interface{}{"env": "prod"},
+		ipNet:       ipNet,
+		agentStatus: cmp.AgentStatusOnline,
+	}
+
+	instance := &cmp.InstanceOutput{
+		Name:        "web-1",
+		Ip:          "192.168.1.1,10.0.0.12",
+		AgentStatus: cmp.AgentStatusOnline,
+		Tags:        []*cmp.InstanceTag{{Key: "env", Value: "prod"}},
+	}
+	if !filter.matches(instance) {
+		t.Fatal("expected instance to match")
+	}
+
+	instance.Tags[0].Value = "dev"
+	if filter.matches(instance) {
+		t.Fatal("expected tag mismatch")
+	}
+
+	instance.Tags[0].Value = "prod"
+	instance.Ip = "10.0.1.12"
+	if filter.matches(instance) {
+		t.Fatal("expected IP mismatch")
+	}
+}
+
+func testInstancesDataSourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+data "bingo_cmp_instances" "dev" {
+  host_type    = "1"
+  name_regex   = ".*"
+  agent_status = "online"
+}
+`)
+}
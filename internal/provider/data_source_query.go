@@ -0,0 +1,182 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+	"terraform-provider-bingo/utils"
+)
+
+const (
+	queryModeSingle = "single"
+	queryModeList   = "list"
+)
+
+func dataSourceCmpQuery() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "通过`api/getEntity`或`api/queryPageList`执行任意CMP查询",
+
+		ReadContext: dataSourceCmpQueryRead,
+
+		Schema: map[string]*schema.Schema{
+			"con_str": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "数据源，如`deploy`",
+			},
+			"sql_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "查询ID，如`command.selectRecordById`",
+			},
+			"params": {
+				Type:          schema.TypeMap,
+				Optional:      true,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"params_json"},
+				Description:   "查询参数",
+			},
+			"params_json": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ValidateFunc:  validation.StringIsJSON,
+				ConflictsWith: []string{"params"},
+				Description:   "JSON格式的查询参数，用于非字符串类型的参数",
+			},
+			"mode": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      queryModeSingle,
+				ValidateFunc: validation.StringInSlice([]string{queryModeSingle, queryModeList}, false),
+				Description:  "查询模式，`single`:单条记录,`list`:分页查询全部记录",
+			},
+			"page_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      cmp.DefaultPageSize,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "`list`模式下每页的记录数",
+			},
+			"max_items": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "`list`模式下最多返回的记录数，0表示不限制",
+			},
+			"result_json": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "JSON格式的查询结果，`list`模式下为数组",
+			},
+			"results": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeMap, Elem: &schema.Schema{Type: schema.TypeString}},
+				Description: "查询结果，每条记录的嵌套字段以JSON字符串表示",
+			},
+		},
+	}
+}
+
+func dataSourceCmpQueryRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	conStr := d.Get("con_str").(string)
+	sqlId := d.Get("sql_id").(string)
+
+	var params interface{} = d.Get("params").(map[string]interface{})
+	if v, ok := d.GetOk("params_json"); ok {
+		if err := json.Unmarshal([]byte(v.(string)), &params); err != nil {
+			return diagErrorf("[CMP] Invalid params_json: %s", err)
+		}
+	}
+
+	var items []json.RawMessage
+	if d.Get("mode").(string) == queryModeList {
+		opts := cmp.PaginateOptions{PageSize: d.Get("page_size").(int), MaxItems: d.Get("max_items").(int)}
+		if err := client.cmpClient.Paginate(ctx, conStr, sqlId, params, opts).All(&items); err != nil {
+			return diagErrorf("[CMP] Unable to query %s, got error: %s", sqlId, err)
+		}
+		if items == nil {
+			items = []json.RawMessage{}
+		}
+	} else {
+		item, err := client.cmpClient.GetEntity(ctx, &cmp.GetEntityInput{ConStr: conStr, SqlId: sqlId, Params: params})
+		if err != nil {
+			return diagErrorf("[CMP] Unable to query %s, got error: %s", sqlId, err)
+		}
+		if len(bytes.TrimSpace(item)) > 0 && string(bytes.TrimSpace(item)) != "null" {
+			items = []json.RawMessage{item}
+		}
+	}
+
+	results := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		results = append(results, flattenQueryItem(item))
+	}
+
+	var resultJSON []byte
+	var err error
+	if d.Get("mode").(string) == queryModeList {
+		resultJSON, err = json.Marshal(items)
+	} else if len(items) == 1 {
+		resultJSON = items[0]
+	} else {
+		resultJSON = []byte("null")
+	}
+	if err != nil {
+		return diagErrorf("[CMP] Unable to encode result of %s: %s", sqlId, err)
+	}
+
+	d.SetId(strconv.Itoa(schema.HashString(conStr + sqlId + utils.PrettifyJSON(params))))
+	d.Set("result_json", utils.Redact(string(resultJSON)))
+	if err := d.Set("results", results); err != nil {
+		return diagErrorf("[CMP] Unable to set results: %s", err)
+	}
+
+	tflog.Debug(ctx, "[CMP] Queried successfully", utils.RedactFields(map[string]interface{}{
+		"con_str": conStr,
+		"sql_id":  sqlId,
+		"params":  params,
+		"total":   len(results),
+	}))
+
+	return nil
+}
+
+// flattenQueryItem turns a JSON object into a map of strings, nested values are kept as JSON.
+// A scalar item is returned as the single key `value`.
+func flattenQueryItem(item json.RawMessage) map[string]interface{} {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(item, &fields); err != nil {
+		return map[string]interface{}{"value": utils.Redact(jsonString(item))}
+	}
+
+	result := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		result[k] = utils.Redact(jsonString(v))
+	}
+	return result
+}
+
+// jsonString returns strings unquoted, null as empty and any other value as its JSON text.
+func jsonString(v json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		return s
+	}
+	if string(bytes.TrimSpace(v)) == "null" {
+		return ""
+	}
+	return string(bytes.TrimSpace(v))
+}
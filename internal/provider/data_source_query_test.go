@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestQueryDataSource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testQueryDataSourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.bingo_cmp_query.dev", "results.#", "1"),
+					resource.TestCheckResourceAttrPair("data.bingo_cmp_query.dev", "results.0.id", "bingo_cmp_command.dev", "record_id"),
+				),
+			},
+		},
+	})
+}
+
+func TestFlattenQueryItem(t *testing.T) {
+	got := flattenQueryItem(json.RawMessage(`{"id":"r1","count":2,"tags":["a"],"desc":null}`))
+	want := map[string]interface{}{"id": "r1", "count": "2", "tags": `["a"]`, "desc": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result: %v", got)
+	}
+
+	if got := flattenQueryItem(json.RawMessage(`"scalar"`)); got["value"] != "scalar" {
+		t.Fatalf("unexpected result: %v", got)
+	}
+}
+
+func testQueryDataSourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_command" "dev" {
+  host_type   	= "1" 
+  content     	= "pwd"
+  instance_ids	= "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+}
+
+data "bingo_cmp_query" "dev" {
+  con_str = "deploy"
+  sql_id  = "command.selectRecordById"
+  params  = {
+    id = bingo_cmp_command.dev.record_id
+  }
+}
+`)
+}
@@ -0,0 +1,240 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+const (
+	waitForStateWaiting = "waiting"
+	waitForStatePassed  = "passed"
+)
+
+func dataSourceCmpWaitFor() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "反复下发检查命令，直到全部或指定数量的实例检查通过，用于部署后等待服务就绪（仅支持Linux）",
+
+		ReadContext: dataSourceCmpWaitForRead,
+
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(10 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"host_type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.HostTypeVirtualMachine, cmp.HostTypePhysical}, false),
+				Description:  "宿主机类型，1:虚拟机,2:物理机",
+			},
+			"instance_ids": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "实例编号，多个用逗号分割",
+			},
+			"content": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "检查命令，执行成功即为通过，如`curl -fsS localhost:8080/health`",
+			},
+			"output_regex": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsValidRegExp,
+				Description:  "检查命令输出须匹配的正则表达式，为空时仅判断是否执行成功",
+			},
+			"quorum": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "须通过检查的实例数，0表示全部实例",
+			},
+			"success_threshold": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "实例连续通过检查的次数达到该值才视为通过",
+			},
+			"interval": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "10s",
+				ValidateFunc: validateDuration,
+				Description:  "检查间隔，如`10s`",
+			},
+			"passed_instance_ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "通过检查的实例编号",
+			},
+			"instance_outputs": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "各实例最后一次检查的输出",
+			},
+			"attempts": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "检查次数",
+			},
+		},
+	}
+}
+
+func dataSourceCmpWaitForRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	instanceIds := splitInstanceIds(d.Get("instance_ids").(string))
+	interval, _ := time.ParseDuration(d.Get("interval").(string))
+	timeout := d.Timeout(schema.TimeoutRead)
+
+	check := newWaitForCheck(instanceIds, d.Get("quorum").(int), d.Get("success_threshold").(int))
+	if v, ok := d.GetOk("output_regex"); ok {
+		check.outputRegex = regexp.MustCompile(v.(string))
+	}
+
+	content := managedStateCheckScript(d.Get("content").(string))
+	stateConf := &resource.StateChangeConf{
+		Pending: []string{waitForStateWaiting},
+		Target:  []string{waitForStatePassed},
+		Refresh: func() (interface{}, string, error) {
+			results, err := runCommand(ctx, client, &commandRun{
+				HostType:    d.Get("host_type").(string),
+				InstanceIds: check.pending(),
+				Name:        "terraform-wait-for",
+				Content:     content,
+				Timeout:     timeout,
+			})
+			if err != nil {
+				return nil, "", err
+			}
+			check.observe(results)
+			if check.done() {
+				return check, waitForStatePassed, nil
+			}
+			return check, waitForStateWaiting, nil
+		},
+		Timeout:      timeout,
+		PollInterval: interval,
+	}
+
+	_, err := stateConf.WaitForStateContext(ctx)
+
+	d.SetId(strconv.Itoa(schema.HashString(strings.Join(instanceIds, ",") + d.Get("content").(string))))
+	d.Set("passed_instance_ids", check.passedIds())
+	d.Set("instance_outputs", check.outputs)
+	d.Set("attempts", check.attempts)
+
+	if err != nil {
+		return diagErrorf("[CMP] Waiting for %d of %d instances to pass the check: %s, last outputs: %s",
+			check.required(), len(instanceIds), err, check.failedOutputs())
+	}
+
+	tflog.Debug(ctx, "[CMP] Check passed", map[string]interface{}{
+		"passed":   len(check.passedIds()),
+		"attempts": check.attempts,
+	})
+
+	return nil
+}
+
+// waitForCheck tracks the consecutive passes of each instance, an instance reaching the threshold
+// has passed and is no longer checked.
+type waitForCheck struct {
+	instanceIds []string
+	quorum      int
+	threshold   int
+	outputRegex *regexp.Regexp
+
+	attempts int
+	passes   map[string]int
+	passed   map[string]bool
+	outputs  map[string]interface{}
+}
+
+func newWaitForCheck(instanceIds []string, quorum, threshold int) *waitForCheck {
+	return &waitForCheck{
+		instanceIds: instanceIds,
+		quorum:      quorum,
+		threshold:   threshold,
+		passes:      map[string]int{},
+		passed:      map[string]bool{},
+		outputs:     map[string]interface{}{},
+	}
+}
+
+func (its *waitForCheck) observe(results map[string]*commandResult) {
+	its.attempts++
+	for id, result := range results {
+		output, ok := managedStateOutput(result)
+		rc, _ := result.Marker("rc")
+		its.outputs[id] = output
+		if ok && rc == "0" && (its.outputRegex == nil || its.outputRegex.MatchString(output)) {
+			its.passes[id]++
+		} else {
+			its.passes[id] = 0
+		}
+		if its.passes[id] >= its.threshold {
+			its.passed[id] = true
+		}
+	}
+}
+
+// required is the number of instances which must pass.
+func (its *waitForCheck) required() int {
+	if its.quorum <= 0 || its.quorum > len(its.instanceIds) {
+		return len(its.instanceIds)
+	}
+	return its.quorum
+}
+
+func (its *waitForCheck) done() bool {
+	return len(its.passed) >= its.required()
+}
+
+func (its *waitForCheck) pending() []string {
+	var ids []string
+	for _, id := range its.instanceIds {
+		if !its.passed[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (its *waitForCheck) passedIds() []string {
+	var ids []string
+	for _, id := range its.instanceIds {
+		if its.passed[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (its *waitForCheck) failedOutputs() string {
+	var outputs []string
+	for _, id := range its.pending() {
+		outputs = append(outputs, fmt.Sprintf("%s: %q", id, its.outputs[id]))
+	}
+	sort.Strings(outputs)
+	return strings.Join(outputs, ", ")
+}
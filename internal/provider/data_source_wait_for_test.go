@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestWaitForDataSource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testWaitForDataSourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.bingo_cmp_wait_for.dev", "passed_instance_ids.#", "1"),
+				),
+			},
+		},
+	})
+}
+
+// checkResult is the result of managedStateCheckScript printing output and exiting with rc.
+func checkResult(output string, rc int) *commandResult {
+	return &commandResult{
+		Status: "success",
+		Log: fmt.Sprintf("%soutput=%s\n%src=%d\n",
+			markerPrefix, base64.StdEncoding.EncodeToString([]byte(output)), markerPrefix, rc),
+	}
+}
+
+func TestWaitForCheck(t *testing.T) {
+	check := newWaitForCheck([]string{"vm-1", "vm-2", "vm-3"}, 2, 2)
+	check.outputRegex = regexp.MustCompile(`"status":"UP"`)
+
+	check.observe(map[string]*commandResult{
+		"vm-1": checkResult(`{"status":"UP"}`, 0),
+		"vm-2": checkResult(`{"status":"UP"}`, 0),
+		"vm-3": checkResult("connection refused", 7),
+	})
+	if check.done() || len(check.pending()) != 3 {
+		t.Fatalf("one pass is below the threshold: %v", check.passes)
+	}
+
+	check.observe(map[string]*commandResult{
+		"vm-1": checkResult(`{"status":"UP"}`, 0),
+		"vm-2": checkResult(`{"status":"DOWN"}`, 0),
+		"vm-3": checkResult(`{"status":"UP"}`, 0),
+	})
+	if check.done() || fmt.Sprint(check.pending()) != "[vm-2 vm-3]" {
+		t.Fatalf("unexpected pending instances %v", check.pending())
+	}
+
+	check.observe(map[string]*commandResult{
+		"vm-2": checkResult(`{"status":"UP"}`, 0),
+		"vm-3": checkResult(`{"status":"UP"}`, 0),
+	})
+	if !check.done() || fmt.Sprint(check.passedIds()) != "[vm-1 vm-3]" || check.attempts != 3 {
+		t.Fatalf("unexpected passed instances %v after %d attempts", check.passedIds(), check.attempts)
+	}
+}
+
+func testWaitForDataSourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+data "bingo_cmp_wait_for" "dev" {
+  host_type    = "1"
+  instance_ids = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  content      = "curl -fsS localhost:8080/health"
+  interval     = "5s"
+
+  timeouts {
+    read = "5m"
+  }
+}
+`)
+}
@@ -0,0 +1,227 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+const (
+	osFamilyLinux   = "linux"
+	osFamilyWindows = "windows"
+)
+
+var packageNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.+:@-][A-Za-z0-9_.+:@ -]*$`)
+
+// hostFacts is what the fact-gathering scripts report as the JSON `facts` marker.
+type hostFacts struct {
+	Hostname      string            `json:"hostname"`
+	OsFamily      string            `json:"os_family"`
+	Distribution  string            `json:"distribution"`
+	OsName        string            `json:"os_name"`
+	OsVersion     string            `json:"os_version"`
+	KernelVersion string            `json:"kernel_version"`
+	Architecture  string            `json:"architecture"`
+	CpuCount      int               `json:"cpu_count"`
+	MemoryMb      int               `json:"memory_mb"`
+	Packages      map[string]string `json:"packages"`
+}
+
+// osFamily maps the OS type CMP reports for an instance to the script to run, anything but Windows is Linux.
+func osFamily(osType string) string {
+	if strings.Contains(strings.ToLower(osType), osFamilyWindows) {
+		return osFamilyWindows
+	}
+	return osFamilyLinux
+}
+
+// groupInstancesByOsFamily groups the instances by the OS family CMP reports for them.
+func groupInstancesByOsFamily(ctx context.Context, client *bingoCloudClient, hostType string, instanceIds []string) (map[string][]string, error) {
+	instances, err := client.cmpClient.ListInstances(ctx, &cmp.ListInstancesInput{
+		HostType: hostType,
+		Params:   cmp.ListInstancesParams{Ids: instanceIds},
+	})
+	if err != nil {
+		return nil, err
+	}
+	families := map[string]string{}
+	for _, instance := range instances {
+		families[instance.Id] = osFamily(instance.OsType)
+	}
+
+	groups := map[string][]string{}
+	for _, id := range instanceIds {
+		family, ok := families[id]
+		if !ok {
+			return nil, fmt.Errorf("instance %s not found", id)
+		}
+		groups[family] = append(groups[family], id)
+	}
+	return groups, nil
+}
+
+// linuxFactsScript prints the facts of a Linux host, the versions of packages come from rpm or dpkg
+// and are empty when a package is not installed.
+func linuxFactsScript(packages []string) string {
+	var b strings.Builder
+	b.WriteString(`__tf_bingo_json() { printf '"%s"' "$(printf '%s' "$1" | sed -e 's/\\/\\\\/g' -e 's/"/\\"/g' | tr -d '\n\r\t')"; }
+[ -r /etc/os-release ] && . /etc/os-release
+__tf_bingo_cpu=$(getconf _NPROCESSORS_ONLN 2>/dev/null || nproc 2>/dev/null || echo 0)
+__tf_bingo_mem=$(awk '/^MemTotal:/ {printf "%d", $2 / 1024}' /proc/meminfo 2>/dev/null)
+__tf_bingo_pkgs=""
+`)
+	for _, name := range packages {
+		// rpm prints `package x is not installed` to stdout, multilib packages print one version per line.
+		fmt.Fprintf(&b, "__tf_bingo_ver=$({ rpm -q --qf '%%{VERSION}-%%{RELEASE}\\n' %[1]s 2>/dev/null || dpkg-query -W -f='${Version}\\n' %[1]s 2>/dev/null; } | grep -v 'not installed' | tail -n 1)\n", shellQuote(name))
+		fmt.Fprintf(&b, "__tf_bingo_pkgs=\"$__tf_bingo_pkgs${__tf_bingo_pkgs:+,}$(__tf_bingo_json %s):$(__tf_bingo_json \"$__tf_bingo_ver\")\"\n", shellQuote(name))
+	}
+	fmt.Fprintf(&b, "printf '%sfacts={%s}\\n' %s\n", markerPrefix, strings.Join([]string{
+		`"hostname":%s`,
+		`"os_family":"linux"`,
+		`"distribution":%s`,
+		`"os_name":%s`,
+		`"os_version":%s`,
+		`"kernel_version":%s`,
+		`"architecture":%s`,
+		`"cpu_count":%s`,
+		`"memory_mb":%s`,
+		`"packages":{%s}`,
+	}, ","), strings.Join([]string{
+		`"$(__tf_bingo_json "$(hostname)")"`,
+		`"$(__tf_bingo_json "${ID:-}")"`,
+		`"$(__tf_bingo_json "${NAME:-$(uname -s)}")"`,
+		`"$(__tf_bingo_json "${VERSION_ID:-}")"`,
+		`"$(__tf_bingo_json "$(uname -r)")"`,
+		`"$(__tf_bingo_json "$(uname -m)")"`,
+		`"${__tf_bingo_cpu:-0}"`,
+		`"${__tf_bingo_mem:-0}"`,
+		`"$__tf_bingo_pkgs"`,
+	}, " "))
+	return b.String()
+}
+
+// windowsFactsScript prints the facts of a Windows host with PowerShell, the versions of packages come
+// from the uninstall registry keys and are empty when a package is not installed.
+func windowsFactsScript(packages []string) string {
+	names := make([]string, 0, len(packages))
+	for _, name := range packages {
+		names = append(names, powershellQuote(name))
+	}
+
+	var b strings.Builder
+	b.WriteString("$os = Get-CimInstance Win32_OperatingSystem\n")
+	b.WriteString("$cs = Get-CimInstance Win32_ComputerSystem\n")
+	b.WriteString("$installed = Get-ItemProperty 'HKLM:\\Software\\Microsoft\\Windows\\CurrentVersion\\Uninstall\\*', 'HKLM:\\Software\\WOW6432Node\\Microsoft\\Windows\\CurrentVersion\\Uninstall\\*' -ErrorAction SilentlyContinue\n")
+	b.WriteString("$packages = @{}\n")
+	fmt.Fprintf(&b, "foreach ($name in @(%s)) {\n", strings.Join(names, ", "))
+	b.WriteString("  $p = $installed | Where-Object { $_.DisplayName -eq $name } | Select-Object -First 1\n")
+	b.WriteString("  $packages[$name] = if ($p) { [string]$p.DisplayVersion } else { '' }\n")
+	b.WriteString("}\n")
+	b.WriteString("$facts = [ordered]@{\n")
+	b.WriteString("  hostname = $env:COMPUTERNAME\n")
+	b.WriteString("  os_family = 'windows'\n")
+	b.WriteString("  distribution = 'windows'\n")
+	b.WriteString("  os_name = [string]$os.Caption\n")
+	b.WriteString("  os_version = [string]$os.Version\n")
+	b.WriteString("  kernel_version = [string]$os.BuildNumber\n")
+	b.WriteString("  architecture = [string]$os.OSArchitecture\n")
+	b.WriteString("  cpu_count = [int]$cs.NumberOfLogicalProcessors\n")
+	b.WriteString("  memory_mb = [int]($cs.TotalPhysicalMemory / 1MB)\n")
+	b.WriteString("  packages = $packages\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "Write-Output ('%sfacts=' + ($facts | ConvertTo-Json -Compress))\n", markerPrefix)
+	return b.String()
+}
+
+// powershellQuote quotes s as a single quoted PowerShell string.
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// parseHostFacts decodes the `facts` marker of result.
+func parseHostFacts(result *commandResult) (*hostFacts, error) {
+	if !result.Succeeded() {
+		return nil, fmt.Errorf("fact-gathering failed with status %s", result.Status)
+	}
+	v, ok := result.Marker("facts")
+	if !ok {
+		return nil, fmt.Errorf("no facts reported")
+	}
+	facts := &hostFacts{}
+	if err := json.Unmarshal([]byte(v), facts); err != nil {
+		return nil, fmt.Errorf("unable to parse facts %s: %s", v, err)
+	}
+	return facts, nil
+}
+
+// factCache keeps the facts gathered during the lifetime of the provider, which is one plan or apply,
+// so data sources reading the same hosts gather them once. Failures are not cached.
+type factCache struct {
+	mu      sync.Mutex
+	entries map[string]*factEntry
+}
+
+type factEntry struct {
+	done  chan struct{}
+	facts *hostFacts
+	err   error
+}
+
+func newFactCache() *factCache {
+	return &factCache{entries: map[string]*factEntry{}}
+}
+
+// Get returns the facts of the instances, gather is called with the instances which are neither cached
+// nor being gathered by another caller. prefix distinguishes requests gathering different facts.
+func (its *factCache) Get(ctx context.Context, prefix string, instanceIds []string, gather func([]string) (map[string]*hostFacts, error)) (map[string]*hostFacts, error) {
+	its.mu.Lock()
+	var owned []string
+	entries := make(map[string]*factEntry, len(instanceIds))
+	for _, id := range instanceIds {
+		entry, ok := its.entries[prefix+id]
+		if !ok {
+			entry = &factEntry{done: make(chan struct{})}
+			its.entries[prefix+id] = entry
+			owned = append(owned, id)
+		}
+		entries[id] = entry
+	}
+	its.mu.Unlock()
+
+	if len(owned) > 0 {
+		gathered, err := gather(owned)
+		its.mu.Lock()
+		for _, id := range owned {
+			entry := entries[id]
+			entry.facts, entry.err = gathered[id], err
+			if entry.err == nil && entry.facts == nil {
+				entry.err = fmt.Errorf("no facts reported")
+			}
+			if entry.err != nil {
+				delete(its.entries, prefix+id)
+			}
+			close(entry.done)
+		}
+		its.mu.Unlock()
+	}
+
+	facts := make(map[string]*hostFacts, len(entries))
+	for _, id := range instanceIds {
+		entry := entries[id]
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		facts[id] = entry.facts
+	}
+	return facts, nil
+}
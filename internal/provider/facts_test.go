@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLinuxFactsScript(t *testing.T) {
+	output, err := exec.Command("sh", "-c", linuxFactsScript([]string{"terraform-bingo-missing", "it's"})).CombinedOutput()
+	if err != nil {
+		t.Fatalf("script failed: %s\n%s", err, output)
+	}
+
+	facts, err := parseHostFacts(&commandResult{Status: "success", Log: string(output)})
+	if err != nil {
+		t.Fatalf("err: %s\n%s", err, output)
+	}
+	if facts.OsFamily != osFamilyLinux || facts.KernelVersion == "" || facts.Architecture == "" || facts.CpuCount < 1 {
+		t.Fatalf("unexpected facts: %+v", facts)
+	}
+	if v, ok := facts.Packages["terraform-bingo-missing"]; !ok || v != "" {
+		t.Fatalf("unexpected packages: %v", facts.Packages)
+	}
+	if _, ok := facts.Packages["it's"]; !ok {
+		t.Fatalf("unexpected packages: %v", facts.Packages)
+	}
+}
+
+func TestLinuxFactsScriptWithRpm(t *testing.T) {
+	// A fake rpm knowing a single package, it reports the others as not installed like rpm does.
+	dir := t.TempDir()
+	rpm := `#!/bin/sh
+for name; do :; done
+if [ "$name" = bash ]; then printf '5.1.8-6.el9\n'; exit 0; fi
+echo "package $name is not installed"
+exit 1
+`
+	if err := os.WriteFile(filepath.Join(dir, "rpm"), []byte(rpm), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("sh", "-c", linuxFactsScript([]string{"bash", "terraform-bingo-missing"}))
+	cmd.Env = append(os.Environ(), "PATH="+dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("script failed: %s\n%s", err, output)
+	}
+
+	facts, err := parseHostFacts(&commandResult{Status: "success", Log: string(output)})
+	if err != nil {
+		t.Fatalf("err: %s\n%s", err, output)
+	}
+	if facts.Packages["bash"] != "5.1.8-6.el9" || facts.Packages["terraform-bingo-missing"] != "" {
+		t.Fatalf("unexpected packages: %v", facts.Packages)
+	}
+}
+
+func TestWindowsFactsScript(t *testing.T) {
+	script := windowsFactsScript([]string{"Git", "it's"})
+	if !strings.Contains(script, "@('Git', 'it''s')") || !strings.Contains(script, "'__TF_BINGO__facts='") {
+		t.Fatalf("unexpected script:\n%s", script)
+	}
+	if osFamily("Windows Server 2019") != osFamilyWindows || osFamily("CentOS 7") != osFamilyLinux || osFamily("") != osFamilyLinux {
+		t.Fatal("unexpected os family")
+	}
+}
+
+func TestFactCache(t *testing.T) {
+	cache := newFactCache()
+	var mu sync.Mutex
+	var gathered []string
+	gather := func(ids []string) (map[string]*hostFacts, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		gathered = append(gathered, ids...)
+		facts := map[string]*hostFacts{}
+		for _, id := range ids {
+			facts[id] = &hostFacts{Hostname: id}
+		}
+		return facts, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			facts, err := cache.Get(context.Background(), "1||", []string{"vm-1", "vm-2"}, gather)
+			if err != nil || facts["vm-2"].Hostname != "vm-2" {
+				t.Errorf("unexpected facts %v: %v", facts, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := cache.Get(context.Background(), "1||", []string{"vm-2", "vm-3"}, gather); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := fmt.Sprint(gathered); got != "[vm-1 vm-2 vm-3]" {
+		t.Fatalf("expected every instance to be gathered once, got %s", got)
+	}
+
+	// Failures are not cached.
+	failing := func(ids []string) (map[string]*hostFacts, error) { return nil, errors.New("agent offline") }
+	if _, err := cache.Get(context.Background(), "1||", []string{"vm-4"}, failing); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := cache.Get(context.Background(), "1||", []string{"vm-4"}, gather); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,269 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	jobStepStatusSuccess    = "success"
+	jobStepStatusFailed     = "failed"
+	jobStepStatusSkipped    = "skipped"
+	jobStepStatusRolledBack = "rolled_back"
+)
+
+const (
+	jobOnFailureStop     = "stop"
+	jobOnFailureContinue = "continue"
+	jobOnFailureRollback = "rollback"
+)
+
+// jobStep is a step of a bingo_cmp_job.
+type jobStep struct {
+	Name            string
+	Content         string
+	RollbackContent string
+	InstanceIds     []string
+	DependsOn       []string
+	Timeout         time.Duration
+	Retries         int
+	RetryInterval   time.Duration
+	OnFailure       string
+}
+
+// jobStepResult is the outcome of a step, InstanceStatus and Message are those of the last attempt.
+type jobStepResult struct {
+	Name           string
+	Status         string
+	RecordId       string
+	TaskId         string
+	Attempts       int
+	InstanceStatus map[string]interface{}
+	Message        string
+}
+
+// jobStepRunner runs the content of step once, it is runCommand outside of tests.
+type jobStepRunner func(ctx context.Context, step *jobStep, content string) (map[string]*commandResult, error)
+
+// validateJobSteps checks the step names and dependencies, a dependency cycle is reported as an error.
+func validateJobSteps(steps []*jobStep) error {
+	index := map[string]int{}
+	for i, step := range steps {
+		if _, ok := index[step.Name]; ok {
+			return fmt.Errorf("duplicate step name %q", step.Name)
+		}
+		index[step.Name] = i
+	}
+	for _, step := range steps {
+		for _, dep := range step.DependsOn {
+			if _, ok := index[dep]; !ok {
+				return fmt.Errorf("step %q depends on unknown step %q", step.Name, dep)
+			}
+		}
+	}
+
+	// Depth-first search, a step reached again while it is still on the stack closes a cycle.
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make([]int, len(steps))
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		path = append(path, steps[i].Name)
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("steps depend on each other: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		for _, dep := range steps[i].DependsOn {
+			if err := visit(index[dep], path); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		return nil
+	}
+	for i := range steps {
+		if err := visit(i, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runJob runs the steps once their dependencies have ended, independent steps run concurrently.
+// A failed step whose on_failure is `stop` or `rollback` keeps the remaining steps from starting,
+// `rollback` then runs the rollback content of the failed step and of the succeeded steps in
+// reverse order. The results are in the order of steps.
+func runJob(ctx context.Context, steps []*jobStep, run jobStepRunner) ([]*jobStepResult, error) {
+	results := make([]*jobStepResult, len(steps))
+	index := map[string]int{}
+	for i, step := range steps {
+		index[step.Name] = i
+	}
+
+	type completion struct {
+		index  int
+		result *jobStepResult
+	}
+	done := make(chan completion)
+	running := 0
+	var aborted, rollback bool
+	var failed []string
+	var succeeded []int
+
+	for {
+		// Skipping a step can make a step earlier in the list ready, so scan until nothing changes.
+		for changed := !aborted; changed; {
+			changed = false
+			for i, step := range steps {
+				if results[i] != nil || !jobStepReady(step, results, index) {
+					continue
+				}
+				changed = true
+				if jobStepBlocked(step, steps, results, index) {
+					results[i] = &jobStepResult{Name: step.Name, Status: jobStepStatusSkipped, Message: "a dependency did not succeed"}
+					continue
+				}
+				results[i] = &jobStepResult{Name: step.Name}
+				running++
+				go func(i int, step *jobStep) {
+					done <- completion{index: i, result: runJobStep(ctx, step, run)}
+				}(i, step)
+			}
+		}
+		if running == 0 {
+			break
+		}
+
+		c := <-done
+		running--
+		results[c.index] = c.result
+		step := steps[c.index]
+		if c.result.Status == jobStepStatusSuccess {
+			succeeded = append(succeeded, c.index)
+			continue
+		}
+		failed = append(failed, step.Name)
+		switch step.OnFailure {
+		case jobOnFailureContinue:
+		case jobOnFailureRollback:
+			aborted, rollback = true, true
+		default:
+			aborted = true
+		}
+	}
+
+	for i, step := range steps {
+		if results[i] == nil {
+			results[i] = &jobStepResult{Name: step.Name, Status: jobStepStatusSkipped, Message: "the job stopped"}
+		}
+	}
+
+	if rollback {
+		// Undo the failed steps first, then the succeeded ones from the last to end.
+		var undo []int
+		for i, result := range results {
+			if result.Status == jobStepStatusFailed && steps[i].OnFailure == jobOnFailureRollback {
+				undo = append(undo, i)
+			}
+		}
+		for i := len(succeeded) - 1; i >= 0; i-- {
+			undo = append(undo, succeeded[i])
+		}
+		var rollbackErrs []string
+		for _, i := range undo {
+			if steps[i].RollbackContent == "" {
+				continue
+			}
+			if err := runJobRollback(ctx, steps[i], run); err != nil {
+				rollbackErrs = append(rollbackErrs, fmt.Sprintf("%s: %s", steps[i].Name, err))
+				continue
+			}
+			results[i].Status = jobStepStatusRolledBack
+		}
+		if len(rollbackErrs) > 0 {
+			return results, fmt.Errorf("steps failed: %s, rollback failed:\n%s", strings.Join(failed, ", "), strings.Join(rollbackErrs, "\n"))
+		}
+		return results, fmt.Errorf("steps failed: %s, rolled back", strings.Join(failed, ", "))
+	}
+
+	for i, result := range results {
+		if result.Status == jobStepStatusFailed && steps[i].OnFailure != jobOnFailureContinue {
+			return results, fmt.Errorf("steps failed: %s", strings.Join(failed, ", "))
+		}
+	}
+	return results, nil
+}
+
+// jobStepReady reports whether the dependencies of step have all ended.
+func jobStepReady(step *jobStep, results []*jobStepResult, index map[string]int) bool {
+	for _, dep := range step.DependsOn {
+		result := results[index[dep]]
+		if result == nil || result.Status == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// jobStepBlocked reports whether a dependency of step was skipped or failed without on_failure `continue`.
+func jobStepBlocked(step *jobStep, steps []*jobStep, results []*jobStepResult, index map[string]int) bool {
+	for _, dep := range step.DependsOn {
+		i := index[dep]
+		switch results[i].Status {
+		case jobStepStatusSkipped:
+			return true
+		case jobStepStatusFailed:
+			if steps[i].OnFailure != jobOnFailureContinue {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runJobStep runs the step, retrying it up to step.Retries times.
+func runJobStep(ctx context.Context, step *jobStep, run jobStepRunner) *jobStepResult {
+	result := &jobStepResult{Name: step.Name}
+	for {
+		result.Attempts++
+		results, err := run(ctx, step, step.Content)
+		result.InstanceStatus = map[string]interface{}{}
+		for id, r := range results {
+			result.InstanceStatus[id] = r.Status
+			result.RecordId, result.TaskId = r.RecordId, r.TaskId
+		}
+		if err == nil {
+			err = failedResults(results)
+		}
+		if err == nil {
+			result.Status, result.Message = jobStepStatusSuccess, ""
+			return result
+		}
+
+		result.Status, result.Message = jobStepStatusFailed, err.Error()
+		if result.Attempts > step.Retries || ctx.Err() != nil {
+			return result
+		}
+
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(step.RetryInterval):
+		}
+	}
+}
+
+func runJobRollback(ctx context.Context, step *jobStep, run jobStepRunner) error {
+	results, err := run(ctx, step, step.RollbackContent)
+	if err != nil {
+		return err
+	}
+	return failedResults(results)
+}
@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeJobRunner records the scripts it runs and fails the content listed in fail, the number of times given.
+type fakeJobRunner struct {
+	mu   sync.Mutex
+	ran  []string
+	fail map[string]int
+}
+
+func (its *fakeJobRunner) run(ctx context.Context, step *jobStep, content string) (map[string]*commandResult, error) {
+	its.mu.Lock()
+	defer its.mu.Unlock()
+	its.ran = append(its.ran, content)
+
+	status := "success"
+	if its.fail[content] > 0 {
+		its.fail[content]--
+		status = "failed"
+	}
+	results := map[string]*commandResult{}
+	for _, id := range step.InstanceIds {
+		results[id] = &commandResult{InstanceId: id, RecordId: "r-" + content, Status: status}
+	}
+	return results, nil
+}
+
+func testJobSteps(onFailure string, names ...string) []*jobStep {
+	var steps []*jobStep
+	for i, name := range names {
+		step := &jobStep{Name: name, Content: name, RollbackContent: "undo-" + name, InstanceIds: []string{"vm-1"}, OnFailure: onFailure}
+		if i > 0 {
+			step.DependsOn = []string{names[i-1]}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func jobStatuses(results []*jobStepResult) string {
+	var statuses []string
+	for _, result := range results {
+		statuses = append(statuses, result.Name+"="+result.Status)
+	}
+	return strings.Join(statuses, ",")
+}
+
+func TestRunJobInOrder(t *testing.T) {
+	runner := &fakeJobRunner{fail: map[string]int{"install": 1}}
+	steps := testJobSteps(jobOnFailureStop, "stop", "install", "start")
+	steps[1].Retries = 1
+
+	results, err := runJob(context.Background(), steps, runner.run)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := strings.Join(runner.ran, ","); got != "stop,install,install,start" {
+		t.Fatalf("unexpected runs: %s", got)
+	}
+	if results[1].Attempts != 2 || results[1].RecordId != "r-install" || results[1].InstanceStatus["vm-1"] != "success" {
+		t.Fatalf("unexpected result: %+v", results[1])
+	}
+}
+
+func TestRunJobStopsOnFailure(t *testing.T) {
+	runner := &fakeJobRunner{fail: map[string]int{"install": 1}}
+	results, err := runJob(context.Background(), testJobSteps(jobOnFailureStop, "stop", "install", "start"), runner.run)
+	if err == nil {
+		t.Fatal("expected the job to fail")
+	}
+	if got := jobStatuses(results); got != "stop=success,install=failed,start=skipped" {
+		t.Fatalf("unexpected results: %s", got)
+	}
+}
+
+func TestRunJobContinuesOnFailure(t *testing.T) {
+	runner := &fakeJobRunner{fail: map[string]int{"install": 1}}
+	results, err := runJob(context.Background(), testJobSteps(jobOnFailureContinue, "stop", "install", "start"), runner.run)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := jobStatuses(results); got != "stop=success,install=failed,start=success" {
+		t.Fatalf("unexpected results: %s", got)
+	}
+}
+
+func TestRunJobRollsBack(t *testing.T) {
+	runner := &fakeJobRunner{fail: map[string]int{"migrate": 1}}
+	steps := testJobSteps(jobOnFailureRollback, "backup", "install", "migrate", "start")
+	steps[0].RollbackContent = ""
+
+	results, err := runJob(context.Background(), steps, runner.run)
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected a rolled back error, got %v", err)
+	}
+	if got := jobStatuses(results); got != "backup=success,install=rolled_back,migrate=rolled_back,start=skipped" {
+		t.Fatalf("unexpected results: %s", got)
+	}
+	if got := strings.Join(runner.ran, ","); got != "backup,install,migrate,undo-migrate,undo-install" {
+		t.Fatalf("unexpected runs: %s", got)
+	}
+}
+
+func TestRunJobDAG(t *testing.T) {
+	runner := &fakeJobRunner{fail: map[string]int{"b": 1}}
+	steps := []*jobStep{
+		{Name: "d", Content: "d", InstanceIds: []string{"vm-1"}, DependsOn: []string{"c"}},
+		{Name: "a", Content: "a", InstanceIds: []string{"vm-1"}},
+		{Name: "b", Content: "b", InstanceIds: []string{"vm-2"}, OnFailure: jobOnFailureContinue},
+		{Name: "c", Content: "c", InstanceIds: []string{"vm-1"}, DependsOn: []string{"a", "b"}},
+	}
+	if err := validateJobSteps(steps); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	results, err := runJob(context.Background(), steps, runner.run)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := jobStatuses(results); got != "d=success,a=success,b=failed,c=success" {
+		t.Fatalf("unexpected results: %s", got)
+	}
+	if got := runner.ran[len(runner.ran)-2:]; got[0] != "c" || got[1] != "d" {
+		t.Fatalf("unexpected runs: %v", runner.ran)
+	}
+}
+
+func TestValidateJobSteps(t *testing.T) {
+	for _, c := range []struct {
+		steps []*jobStep
+		err   string
+	}{
+		{[]*jobStep{{Name: "a"}, {Name: "a"}}, "duplicate"},
+		{[]*jobStep{{Name: "a", DependsOn: []string{"b"}}}, "unknown"},
+		{[]*jobStep{{Name: "a", DependsOn: []string{"c"}}, {Name: "b", DependsOn: []string{"a"}}, {Name: "c", DependsOn: []string{"b"}}}, "a -> c -> b -> a"},
+	} {
+		if err := validateJobSteps(c.steps); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected error containing %q, got %v", c.err, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// instanceLocker is a provider-wide keyed semaphore, it serializes commands targeting the same instances
+// so two of them never fight over a host, e.g. for the dpkg/yum lock.
+type instanceLocker struct {
+	mu          sync.Mutex
+	changed     chan struct{}
+	perInstance int
+	maxCommands int
+	running     int
+	holders     map[string]int
+}
+
+// newInstanceLocker returns a locker allowing perInstance commands on each instance and maxCommands
+// commands overall, zero maxCommands means unlimited.
+func newInstanceLocker(perInstance, maxCommands int) *instanceLocker {
+	if perInstance < 1 {
+		perInstance = 1
+	}
+	return &instanceLocker{
+		changed:     make(chan struct{}),
+		perInstance: perInstance,
+		maxCommands: maxCommands,
+		holders:     map[string]int{},
+	}
+}
+
+// Lock blocks until a command may run on all of instanceIds, it takes every key at once so overlapping
+// commands can not deadlock. The returned func releases the keys.
+func (its *instanceLocker) Lock(ctx context.Context, instanceIds []string) (func(), error) {
+	keys := uniqueSorted(instanceIds)
+
+	for {
+		its.mu.Lock()
+		if its.available(keys) {
+			for _, k := range keys {
+				its.holders[k]++
+			}
+			its.running++
+			its.mu.Unlock()
+			return func() { its.unlock(keys) }, nil
+		}
+		changed := its.changed
+		its.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (its *instanceLocker) available(keys []string) bool {
+	if its.maxCommands > 0 && its.running >= its.maxCommands {
+		return false
+	}
+	for _, k := range keys {
+		if its.holders[k] >= its.perInstance {
+			return false
+		}
+	}
+	return true
+}
+
+func (its *instanceLocker) unlock(keys []string) {
+	its.mu.Lock()
+	defer its.mu.Unlock()
+
+	for _, k := range keys {
+		if its.holders[k]--; its.holders[k] <= 0 {
+			delete(its.holders, k)
+		}
+	}
+	its.running--
+
+	// Wake up every waiter, each one re-checks whether its keys are available.
+	close(its.changed)
+	its.changed = make(chan struct{})
+}
+
+func uniqueSorted(values []string) []string {
+	seen := map[string]bool{}
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	sort.Strings(result)
+	return result
+}
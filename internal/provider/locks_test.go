@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInstanceLocker(t *testing.T) {
+	locker := newInstanceLocker(1, 0)
+
+	unlock, err := locker.Lock(context.Background(), []string{"vm-1", "vm-2"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A disjoint set of instances is not blocked.
+	unlockOther, err := locker.Lock(context.Background(), []string{"vm-3"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	unlockOther()
+
+	// An overlapping set waits for the first command.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := locker.Lock(ctx, []string{"vm-2", "vm-4"}); err == nil {
+		t.Fatal("expected overlapping instances to be locked")
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		release, err := locker.Lock(context.Background(), []string{"vm-2", "vm-4"})
+		if err == nil {
+			release()
+		}
+		close(acquired)
+	}()
+	unlock()
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected lock to be acquired after release")
+	}
+}
+
+func TestInstanceLockerMaxCommands(t *testing.T) {
+	locker := newInstanceLocker(1, 1)
+
+	unlock, err := locker.Lock(context.Background(), []string{"vm-1"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := locker.Lock(ctx, []string{"vm-2"}); err == nil {
+		t.Fatal("expected max_parallel_commands to be enforced")
+	}
+}
@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+const (
+	// pollerWindow is how long the poller collects record IDs before querying them in one batch.
+	pollerWindow = 2 * time.Second
+	// pollerBatchSize is the maximum number of record IDs queried in one request.
+	pollerBatchSize = 100
+)
+
+type pollResult struct {
+	output *cmp.DescribeCommandOutput
+	err    error
+}
+
+// commandPoller is shared by every resource of a provider instance, it collects the in-flight
+// command records and queries their status in batches instead of one request per resource.
+type commandPoller struct {
+	client  *cmp.Client
+	limiter *cmp.RateLimiter
+
+	mu      sync.Mutex
+	waiters map[string][]chan pollResult
+	running bool
+}
+
+func newCommandPoller(client *cmp.Client, limiter *cmp.RateLimiter) *commandPoller {
+	return &commandPoller{
+		client:  client,
+		limiter: limiter,
+		waiters: map[string][]chan pollResult{},
+	}
+}
+
+// Describe returns the command record recordId, as fetched by the next batch.
+func (its *commandPoller) Describe(ctx context.Context, recordId string) (*cmp.DescribeCommandOutput, error) {
+	ch := make(chan pollResult, 1)
+
+	its.mu.Lock()
+	its.waiters[recordId] = append(its.waiters[recordId], ch)
+	if !its.running {
+		its.running = true
+		go its.loop()
+	}
+	its.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-ch:
+		return result.output, result.err
+	}
+}
+
+// loop flushes the collected record IDs every pollerWindow, it exits once nobody is waiting.
+func (its *commandPoller) loop() {
+	for {
+		time.Sleep(pollerWindow)
+
+		its.mu.Lock()
+		waiters := its.waiters
+		its.waiters = map[string][]chan pollResult{}
+		if len(waiters) == 0 {
+			its.running = false
+			its.mu.Unlock()
+			return
+		}
+		its.mu.Unlock()
+
+		ids := make([]string, 0, len(waiters))
+		for id := range waiters {
+			ids = append(ids, id)
+		}
+		for start := 0; start < len(ids); start += pollerBatchSize {
+			end := start + pollerBatchSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+			its.flush(ids[start:end], waiters)
+		}
+	}
+}
+
+// flush queries ids with the `command.selectRecordByIds` sqlId, which CMP must define to take the `ids` param
+// and return the matching records. If the batch query fails, e.g. the sqlId is missing, every record is queried
+// on its own with `command.selectRecordById` instead.
+func (its *commandPoller) flush(ids []string, waiters map[string][]chan pollResult) {
+	ctx := context.Background()
+	results := map[string]pollResult{}
+
+	err := its.limiter.Wait(ctx)
+	if err == nil {
+		input := &cmp.DescribeCommandsInput{
+			ConStr:   "deploy",
+			SqlId:    "command.selectRecordByIds",
+			Page:     1,
+			PageSize: len(ids),
+		}
+		input.Params.Ids = ids
+
+		var outputs []*cmp.DescribeCommandOutput
+		outputs, err = its.client.DescribeCommands(ctx, input)
+		for _, output := range outputs {
+			results[output.Id] = pollResult{output: output}
+		}
+	}
+	if err != nil {
+		results = its.describeEach(ctx, ids)
+	}
+
+	for _, id := range ids {
+		result, ok := results[id]
+		if !ok {
+			result.err = fmt.Errorf("command record not found (%s)", id)
+		}
+		for _, ch := range waiters[id] {
+			ch <- result
+		}
+	}
+}
+
+// describeEach queries the records one request each, records that do not exist are left out.
+func (its *commandPoller) describeEach(ctx context.Context, ids []string) map[string]pollResult {
+	results := map[string]pollResult{}
+	for _, id := range ids {
+		if err := its.limiter.Wait(ctx); err != nil {
+			results[id] = pollResult{err: err}
+			continue
+		}
+		output, err := its.client.DescribeCommand(ctx, &cmp.DescribeCommandInput{
+			ConStr: "deploy",
+			SqlId:  "command.selectRecordById",
+			Params: struct {
+				Id string `json:"id"`
+			}{Id: id},
+		})
+		if err != nil {
+			results[id] = pollResult{err: err}
+			continue
+		}
+		if output != nil && output.Id != "" {
+			results[id] = pollResult{output: output}
+		}
+	}
+	return results
+}
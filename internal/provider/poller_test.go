@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+func TestCommandPoller(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		input := &cmp.DescribeCommandsInput{}
+		_ = json.NewDecoder(r.Body).Decode(input)
+
+		var outputs []*cmp.DescribeCommandOutput
+		for _, id := range input.Params.Ids {
+			if id != "missing" {
+				outputs = append(outputs, &cmp.DescribeCommandOutput{Id: id, Status: cmp.CommandStatusSuccess})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(outputs)
+	}))
+	defer server.Close()
+
+	poller := newCommandPoller(cmp.New(server.URL, ""), cmp.NewRateLimiter(0, 1))
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"r1", "r2", "r3", "missing"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			output, err := poller.Describe(context.Background(), id)
+			if id == "missing" {
+				if err == nil {
+					t.Errorf("expected error for missing record")
+				}
+				return
+			}
+			if err != nil || output.Id != id {
+				t.Errorf("unexpected result for %s: %v, %v", id, output, err)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("expected a single batched request, got %d", n)
+	}
+}
+
+func TestCommandPollerFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		input := struct {
+			SqlId  string `json:"sqlId"`
+			Params struct {
+				Id string `json:"id"`
+			} `json:"params"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+
+		switch {
+		case input.SqlId == "command.selectRecordByIds":
+			http.Error(w, "sqlId not found", http.StatusInternalServerError)
+		case input.Params.Id == "missing":
+			_, _ = w.Write([]byte("null"))
+		default:
+			_ = json.NewEncoder(w).Encode(&cmp.DescribeCommandOutput{Id: input.Params.Id, Status: cmp.CommandStatusSuccess})
+		}
+	}))
+	defer server.Close()
+
+	poller := newCommandPoller(cmp.New(server.URL, ""), cmp.NewRateLimiter(0, 1))
+
+	output, err := poller.Describe(context.Background(), "r1")
+	if err != nil || output.Id != "r1" {
+		t.Fatalf("unexpected result: %v, %v", output, err)
+	}
+	if _, err := poller.Describe(context.Background(), "missing"); err == nil {
+		t.Fatalf("expected error for missing record")
+	}
+}
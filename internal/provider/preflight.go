@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+// unreachableInstance is a target whose CMP agent can not receive commands.
+type unreachableInstance struct {
+	Id     string
+	Name   string
+	Reason string
+}
+
+func (its unreachableInstance) String() string {
+	if its.Name == "" || its.Name == its.Id {
+		return fmt.Sprintf("%s (%s)", its.Id, its.Reason)
+	}
+	return fmt.Sprintf("%s[%s] (%s)", its.Name, its.Id, its.Reason)
+}
+
+// checkAgents splits instanceIds into the instances whose agent is online and the unreachable ones,
+// so a command is not accepted by CMP only to sit in `new` until it times out.
+func checkAgents(ctx context.Context, client *cmp.Client, hostType string, instanceIds []string) ([]string, []unreachableInstance, error) {
+	instances, err := client.ListInstances(ctx, &cmp.ListInstancesInput{
+		HostType: hostType,
+		Params:   cmp.ListInstancesParams{Ids: instanceIds},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found := make(map[string]*cmp.InstanceOutput, len(instances))
+	for _, instance := range instances {
+		found[instance.Id] = instance
+	}
+
+	var reachable []string
+	var unreachable []unreachableInstance
+	for _, id := range instanceIds {
+		instance, ok := found[id]
+		switch {
+		case !ok:
+			unreachable = append(unreachable, unreachableInstance{Id: id, Reason: "instance not found"})
+		case instance.AgentStatus != cmp.AgentStatusOnline:
+			status := instance.AgentStatus
+			if status == "" {
+				status = "unknown"
+			}
+			unreachable = append(unreachable, unreachableInstance{Id: id, Name: instance.Name, Reason: "agent " + status})
+		default:
+			reachable = append(reachable, id)
+		}
+	}
+
+	return reachable, unreachable, nil
+}
+
+func joinUnreachable(instances []unreachableInstance) string {
+	names := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		names = append(names, instance.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+func unreachableIds(instances []unreachableInstance) []string {
+	ids := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		ids = append(ids, instance.Id)
+	}
+	return ids
+}
@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+func TestCheckAgents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*cmp.InstanceOutput{
+			{Id: "vm-1", Name: "web-1", AgentStatus: cmp.AgentStatusOnline},
+			{Id: "vm-2", Name: "web-2", AgentStatus: cmp.AgentStatusOffline},
+		})
+	}))
+	defer server.Close()
+
+	reachable, unreachable, err := checkAgents(context.Background(), cmp.New(server.URL, ""), cmp.HostTypeVirtualMachine, []string{"vm-1", "vm-2", "vm-3"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(reachable) != 1 || reachable[0] != "vm-1" {
+		t.Fatalf("unexpected reachable instances: %v", reachable)
+	}
+
+	report := joinUnreachable(unreachable)
+	if !strings.Contains(report, "web-2[vm-2] (agent offline)") || !strings.Contains(report, "vm-3 (instance not found)") {
+		t.Fatalf("unexpected report: %s", report)
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	"terraform-provider-bingo/internal/pkg/cmp"
 	"terraform-provider-bingo/internal/pkg/sso"
+	"terraform-provider-bingo/utils"
 )
 
 func init() {
@@ -39,6 +41,9 @@ const (
 
 type bingoCloudClient struct {
 	cmpClient *cmp.Client
+	locker    *instanceLocker
+	poller    *commandPoller
+	facts     *factCache
 }
 
 func New(version string) func() *schema.Provider {
@@ -66,6 +71,7 @@ func New(version string) func() *schema.Provider {
 				"iam_client_secret": {
 					Type:        schema.TypeString,
 					Required:    true,
+					Sensitive:   true,
 					DefaultFunc: schema.EnvDefaultFunc(IAM_CLIENT_SECRET, nil),
 					Description: "IAM颁发的客户端Secret",
 				},
@@ -78,15 +84,70 @@ func New(version string) func() *schema.Provider {
 				"password": {
 					Type:        schema.TypeString,
 					Optional:    true,
+					Sensitive:   true,
 					DefaultFunc: schema.EnvDefaultFunc(PASSWORD, nil),
 					Description: "密码",
 				},
+				"max_parallel_per_instance": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      1,
+					ValidateFunc: validation.IntAtLeast(1),
+					Description:  "同一实例上允许并发执行的指令数",
+				},
+				"max_parallel_commands": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      0,
+					ValidateFunc: validation.IntAtLeast(0),
+					Description:  "全局允许并发执行的指令数，0表示不限制",
+				},
+				"cmp_rate_limit": {
+					Type:         schema.TypeFloat,
+					Optional:     true,
+					Default:      10,
+					ValidateFunc: validation.FloatAtLeast(0),
+					Description:  "调用CMP接口的全局速率（次/秒），0表示不限制",
+				},
+				"cmp_max_concurrency": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      10,
+					ValidateFunc: validation.IntAtLeast(0),
+					Description:  "同时调用CMP接口的最大请求数，0表示不限制",
+				},
+				"poll_rate_limit": {
+					Type:         schema.TypeFloat,
+					Optional:     true,
+					Default:      2,
+					ValidateFunc: validation.FloatAtLeast(0),
+					Description:  "查询指令状态的全局速率（次/秒），0表示不限制。指令状态通过CMP的`command.selectRecordByIds`批量查询，CMP未定义该sqlId时逐条以`command.selectRecordById`查询",
+				},
 			},
 
-			DataSourcesMap: map[string]*schema.Resource{},
+			DataSourcesMap: map[string]*schema.Resource{
+				"bingo_cmp_command":       dataSourceCmpCommand(),
+				"bingo_cmp_command_steps": dataSourceCmpCommandSteps(),
+				"bingo_cmp_commands":      dataSourceCmpCommands(),
+				"bingo_cmp_query":         dataSourceCmpQuery(),
+				"bingo_cmp_instances":     dataSourceCmpInstances(),
+				"bingo_cmp_wait_for":      dataSourceCmpWaitFor(),
+				"bingo_cmp_host_facts":    dataSourceCmpHostFacts(),
+			},
 
 			ResourcesMap: map[string]*schema.Resource{
-				"bingo_cmp_command": resourceCmpCommand(),
+				"bingo_cmp_command":           resourceCmpCommand(),
+				"bingo_cmp_api_request":       resourceCmpApiRequest(),
+				"bingo_cmp_file":              resourceCmpFile(),
+				"bingo_cmp_managed_state":     resourceCmpManagedState(),
+				"bingo_cmp_scheduled_command": resourceCmpScheduledCommand(),
+				"bingo_cmp_script":            resourceCmpScript(),
+				"bingo_cmp_job":               resourceCmpJob(),
+				"bingo_cmp_package":           resourceCmpPackage(),
+				"bingo_cmp_service":           resourceCmpService(),
+				"bingo_cmp_host_user":         resourceCmpHostUser(),
+				"bingo_cmp_ansible_playbook":  resourceCmpAnsiblePlaybook(),
+				"bingo_cmp_instance":          resourceCmpInstance(),
 			},
 		}
 
@@ -105,6 +166,8 @@ func configure(version string, p *schema.Provider) func(context.Context, *schema
 		userName := r.Get("user_name").(string)
 		password := r.Get("password").(string)
 
+		utils.RegisterSecret(iamClientSecret, password)
+
 		var err error
 		auth := &sso.Authorization{}
 		ssoClient := sso.New(iamEndpoint, iamClientId, iamClientSecret, userName, password)
@@ -116,16 +179,36 @@ func configure(version string, p *schema.Provider) func(context.Context, *schema
 		}
 
 		if err != nil {
-			return nil, diag.Errorf(fmt.Sprintf("[SSO] Generate AccessToken failed: %s", err))
+			return nil, diagErrorf("[SSO] Generate AccessToken failed: %s", err)
 		}
 
-		tflog.Trace(ctx, "Generate AT by clientSecret", map[string]interface{}{
+		utils.RegisterSecret(auth.AccessToken, auth.RefreshToken)
+
+		tflog.Trace(ctx, "Generate AT by clientSecret", utils.RedactFields(map[string]interface{}{
 			"input":  []string{iamEndpoint, iamClientId, iamClientSecret},
 			"output": auth,
-		})
+		}))
+
+		cmpClient := cmp.New(cmpEndpoint, auth.AccessToken,
+			cmp.WithRateLimit(r.Get("cmp_rate_limit").(float64)),
+			cmp.WithMaxConcurrency(r.Get("cmp_max_concurrency").(int)),
+		)
 
 		return &bingoCloudClient{
-			cmpClient: cmp.New(cmpEndpoint, auth.AccessToken),
+			cmpClient: cmpClient,
+			locker:    newInstanceLocker(r.Get("max_parallel_per_instance").(int), r.Get("max_parallel_commands").(int)),
+			poller:    newCommandPoller(cmpClient, cmp.NewRateLimiter(r.Get("poll_rate_limit").(float64), 1)),
+			facts:     newFactCache(),
 		}, nil
 	}
 }
+
+// diagErrorf builds an error diagnostic with every registered secret redacted from its summary.
+func diagErrorf(format string, a ...interface{}) diag.Diagnostics {
+	return diag.Diagnostics{
+		diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  utils.Redact(fmt.Sprintf(format, a...)),
+		},
+	}
+}
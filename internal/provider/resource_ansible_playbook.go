@@ -0,0 +1,303 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+	"terraform-provider-bingo/utils"
+)
+
+const ansibleWorkDir = "/var/lib/terraform-bingo/ansible"
+
+var ansibleTagRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func resourceCmpAnsiblePlaybook() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "通过CMP指令将本地的playbook目录打包分片上传到实例，并以`ansible-playbook -c local`在实例上执行（仅支持Linux，实例上须已安装Ansible）。" +
+			"目录内容或任意参数变化时重新执行，执行结果按实例解析自`PLAY RECAP`",
+
+		CreateContext: resourceCmpAnsiblePlaybookCreate,
+		ReadContext:   resourceCmpAnsiblePlaybookRead,
+		DeleteContext: resourceCmpAnsiblePlaybookDelete,
+
+		CustomizeDiff: resourceCmpAnsiblePlaybookCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"host_type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.HostTypeVirtualMachine, cmp.HostTypePhysical}, false),
+				Description:  "宿主机类型，1:虚拟机,2:物理机",
+			},
+			"instance_ids": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "实例编号，多个用逗号分割",
+			},
+			"source_dir": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+				Description:  "本地playbook目录，包含playbook、roles等，不支持符号链接",
+			},
+			"playbook": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "site.yml",
+				ValidateFunc: validatePlaybookPath,
+				Description:  "playbook相对于`source_dir`的路径",
+			},
+			"extra_vars": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Sensitive:   true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "额外变量，以`-e`传给`ansible-playbook`",
+			},
+			"tags": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringMatch(ansibleTagRegexp, "must be an Ansible tag"),
+				},
+				Description: "仅执行带有这些标签的任务",
+			},
+			"triggers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "任意值变化时重新执行",
+			},
+			"source_hash": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "playbook目录打包后的SHA256",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "执行状态，success:成功,failed:失败",
+			},
+			"results": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "各实例的执行结果，与`instance_ids`顺序一致",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"instance_id": {Type: schema.TypeString, Computed: true, Description: "实例编号"},
+						"status":      {Type: schema.TypeString, Computed: true, Description: "执行状态，success:成功,failed:失败"},
+						"ok":          {Type: schema.TypeInt, Computed: true, Description: "成功的任务数"},
+						"changed":     {Type: schema.TypeInt, Computed: true, Description: "产生变更的任务数"},
+						"unreachable": {Type: schema.TypeInt, Computed: true, Description: "不可达的主机数"},
+						"failed":      {Type: schema.TypeInt, Computed: true, Description: "失败的任务数"},
+						"skipped":     {Type: schema.TypeInt, Computed: true, Description: "跳过的任务数"},
+						"rescued":     {Type: schema.TypeInt, Computed: true, Description: "被rescue处理的任务数"},
+						"ignored":     {Type: schema.TypeInt, Computed: true, Description: "忽略错误的任务数"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func validatePlaybookPath(v interface{}, k string) (ws []string, errs []error) {
+	p := v.(string)
+	if p == "" || path.IsAbs(p) || strings.HasPrefix(path.Clean(p), "..") {
+		errs = append(errs, fmt.Errorf("%q must be a relative path inside source_dir, got: %s", k, p))
+	}
+	return
+}
+
+func resourceCmpAnsiblePlaybookCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("source_dir") {
+		return d.SetNewComputed("source_hash")
+	}
+	_, hash, err := archiveDirectory(d.Get("source_dir").(string))
+	if err != nil {
+		return fmt.Errorf("unable to archive source_dir: %s", err)
+	}
+	if hash == d.Get("source_hash").(string) {
+		return nil
+	}
+	if err := d.SetNew("source_hash", hash); err != nil {
+		return err
+	}
+	if d.Id() == "" {
+		return nil
+	}
+	return d.ForceNew("source_hash")
+}
+
+func resourceCmpAnsiblePlaybookCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	archive, hash, err := archiveDirectory(d.Get("source_dir").(string))
+	if err != nil {
+		return diagErrorf("[CMP] Unable to archive %s, got error: %s", d.Get("source_dir"), err)
+	}
+
+	// The ID names the working directory on the instances.
+	d.SetId(resource.UniqueId())
+	d.Set("source_hash", hash)
+
+	instanceIds := splitInstanceIds(d.Get("instance_ids").(string))
+	results, err := runAnsiblePlaybook(ctx, client, d, instanceIds, archive)
+	if err != nil {
+		return diagErrorf("[CMP] Unable to run playbook %s, got error: %s", d.Get("playbook"), err)
+	}
+
+	// A failed run is kept as tainted with its results, so the next apply runs it again.
+	runErr := failedResults(results)
+	d.Set("status", cmp.CommandStatusSuccess)
+	if runErr != nil {
+		d.Set("status", cmp.CommandStatusFailed)
+	}
+	if err := d.Set("results", flattenPlaybookResults(instanceIds, results)); err != nil {
+		return diagErrorf("[CMP] Unable to set results: %s", err)
+	}
+	if runErr != nil {
+		return diagErrorf("[CMP] Playbook %s failed: %s", d.Get("playbook"), runErr)
+	}
+
+	tflog.Debug(ctx, "[CMP] Ran a playbook successfully", map[string]interface{}{
+		"id":       d.Id(),
+		"playbook": d.Get("playbook"),
+	})
+
+	return nil
+}
+
+func resourceCmpAnsiblePlaybookRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// A playbook runs once, its results are those recorded on create.
+	return nil
+}
+
+func resourceCmpAnsiblePlaybookDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	tflog.Debug(ctx, "[CMP] Deleted a playbook run successfully", map[string]interface{}{"id": d.Id()})
+	return nil
+}
+
+// runAnsiblePlaybook uploads the archive in chunks, then extracts and runs the playbook in a working directory
+// which is removed afterwards, since it holds the extra vars.
+func runAnsiblePlaybook(ctx context.Context, client *bingoCloudClient, d *schema.ResourceData, instanceIds []string, archive []byte) (map[string]*commandResult, error) {
+	hostType := d.Get("host_type").(string)
+	timeout := d.Timeout(schema.TimeoutCreate)
+	archivePath := fmt.Sprintf("%s/%s.tar.gz", ansibleWorkDir, d.Id())
+
+	chunks := splitChunks(base64.StdEncoding.EncodeToString(archive), fileChunkSize)
+	for i, chunk := range chunks[:len(chunks)-1] {
+		script := "set -e\n" + fileChunkScript(archivePath, chunk, i > 0)
+		if err := runOnAll(ctx, client, hostType, instanceIds, "terraform-ansible-chunk", script, timeout); err != nil {
+			return nil, err
+		}
+	}
+
+	script, err := ansiblePlaybookScript(d, d.Id(), chunks[len(chunks)-1], len(chunks) > 1)
+	if err != nil {
+		return nil, err
+	}
+	return runCommand(ctx, client, &commandRun{
+		HostType:    hostType,
+		InstanceIds: instanceIds,
+		Name:        "terraform-ansible-playbook",
+		Content:     script,
+		Timeout:     timeout,
+	})
+}
+
+func ansiblePlaybookScript(d resourceGetter, id, lastChunk string, appendChunk bool) (string, error) {
+	archivePath := fmt.Sprintf("%s/%s.tar.gz", ansibleWorkDir, id)
+	workDir := fmt.Sprintf("%s/%s", ansibleWorkDir, id)
+
+	extraVars, err := json.Marshal(d.Get("extra_vars"))
+	if err != nil {
+		return "", err
+	}
+	encodedVars := base64.StdEncoding.EncodeToString(extraVars)
+
+	// The extra vars are sensitive, neither they nor the encoded file may show up in the logs.
+	for _, v := range d.Get("extra_vars").(map[string]interface{}) {
+		utils.RegisterSecret(v.(string))
+	}
+	if len(d.Get("extra_vars").(map[string]interface{})) > 0 {
+		utils.RegisterSecret(encodedVars)
+	}
+
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	b.WriteString(fileChunkScript(archivePath, lastChunk, appendChunk))
+	fmt.Fprintf(&b, "trap 'rm -rf %s %s %s.tf-bingo.b64' EXIT\n", workDir, archivePath, archivePath)
+	fmt.Fprintf(&b, "base64 -d %s.tf-bingo.b64 > %s\n", archivePath, archivePath)
+	fmt.Fprintf(&b, "rm -rf %[1]s && mkdir -p %[1]s && tar -xzf %[2]s -C %[1]s\n", workDir, archivePath)
+	fmt.Fprintf(&b, "cd %s\n", workDir)
+	b.WriteString("command -v ansible-playbook >/dev/null 2>&1 || { echo 'ansible-playbook not found' >&2; exit 127; }\n")
+	fmt.Fprintf(&b, "printf '%%s' %s | base64 -d > .tf-bingo-extra-vars.json\n", encodedVars)
+
+	args := []string{"ansible-playbook", "-i", "localhost,", "-c", "local", "-e", "@.tf-bingo-extra-vars.json"}
+	var tags []string
+	for _, tag := range d.Get("tags").([]interface{}) {
+		tags = append(tags, tag.(string))
+	}
+	if len(tags) > 0 {
+		args = append(args, "--tags", shellQuote(strings.Join(tags, ",")))
+	}
+	args = append(args, shellQuote(d.Get("playbook").(string)))
+	fmt.Fprintf(&b, "ANSIBLE_NOCOLOR=1 ANSIBLE_FORCE_COLOR=0 ANSIBLE_RETRY_FILES_ENABLED=0 %s\n", strings.Join(args, " "))
+	return b.String(), nil
+}
+
+func flattenPlaybookResults(instanceIds []string, results map[string]*commandResult) []interface{} {
+	list := make([]interface{}, 0, len(instanceIds))
+	for _, id := range instanceIds {
+		result := results[id]
+		if result == nil {
+			continue
+		}
+		recap, _ := parsePlayRecap(result.Log)
+		if recap == nil {
+			recap = &playRecap{}
+		}
+		status := cmp.CommandStatusSuccess
+		if !result.Succeeded() {
+			status = cmp.CommandStatusFailed
+		}
+		list = append(list, map[string]interface{}{
+			"instance_id": id,
+			"status":      status,
+			"ok":          recap.Ok,
+			"changed":     recap.Changed,
+			"unreachable": recap.Unreachable,
+			"failed":      recap.Failed,
+			"skipped":     recap.Skipped,
+			"rescued":     recap.Rescued,
+			"ignored":     recap.Ignored,
+		})
+	}
+	return list
+}
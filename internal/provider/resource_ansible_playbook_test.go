@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+
+	"terraform-provider-bingo/utils"
+)
+
+func TestAnsiblePlaybookResource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "site.yml"), []byte("- hosts: all\n  tasks:\n    - ping:\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAnsiblePlaybookResourceConfig(dir),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_ansible_playbook.dev", "status", "success"),
+					resource.TestCheckResourceAttr("bingo_cmp_ansible_playbook.dev", "results.0.failed", "0"),
+				),
+			},
+		},
+	})
+}
+
+func TestAnsiblePlaybookScript(t *testing.T) {
+	d := mapGetter{
+		"playbook":   "playbooks/site.yml",
+		"extra_vars": map[string]interface{}{"db_password": "pl4ybook-s3cr3t"},
+		"tags":       []interface{}{"install", "config"},
+	}
+	script, err := ansiblePlaybookScript(d, "abc", "Y2h1bms=", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"printf '%s' 'Y2h1bms=' >> '/var/lib/terraform-bingo/ansible/abc.tar.gz.tf-bingo.b64'\n",
+		"trap 'rm -rf /var/lib/terraform-bingo/ansible/abc ",
+		"tar -xzf /var/lib/terraform-bingo/ansible/abc.tar.gz -C /var/lib/terraform-bingo/ansible/abc\n",
+		"ansible-playbook -i localhost, -c local -e @.tf-bingo-extra-vars.json --tags 'install,config' 'playbooks/site.yml'\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script does not contain %q:\n%s", want, script)
+		}
+	}
+	if redacted := utils.Redact(script); strings.Contains(redacted, "printf '%s' ey") || strings.Contains(utils.Redact("pl4ybook-s3cr3t"), "s3cr3t") {
+		t.Errorf("extra vars are not redacted:\n%s", redacted)
+	}
+}
+
+func TestValidatePlaybookPath(t *testing.T) {
+	for p, valid := range map[string]bool{
+		"site.yml":           true,
+		"playbooks/site.yml": true,
+		"/etc/site.yml":      false,
+		"../site.yml":        false,
+		"a/../../site.yml":   false,
+	} {
+		_, errs := validatePlaybookPath(p, "playbook")
+		if (len(errs) == 0) != valid {
+			t.Errorf("validatePlaybookPath(%q) = %v, want valid %v", p, errs, valid)
+		}
+	}
+}
+
+func testAnsiblePlaybookResourceConfig(dir string) string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_ansible_playbook" "dev" {
+  host_type    = "1"
+  instance_ids = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  source_dir   = %q
+
+  extra_vars = {
+    env = "dev"
+  }
+}
+`, dir)
+}
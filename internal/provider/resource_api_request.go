@@ -0,0 +1,228 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+	"terraform-provider-bingo/utils"
+)
+
+// apiRequestIdPlaceholder is replaced with the resource ID in the read, update and destroy paths and bodies.
+const apiRequestIdPlaceholder = "{id}"
+
+var apiRequestMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+func resourceCmpApiRequest() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "调用任意CMP接口，用于管理尚未提供专用资源的对象。" +
+			"读取、更新及删除的路径和请求体中的`{id}`会被替换为资源ID",
+
+		CreateContext: resourceCmpApiRequestCreate,
+		ReadContext:   resourceCmpApiRequestRead,
+		UpdateContext: resourceCmpApiRequestUpdate,
+		DeleteContext: resourceCmpApiRequestDelete,
+
+		CustomizeDiff: resourceCmpApiRequestCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"create_method": apiRequestMethodSchema(http.MethodPost, "创建时的请求方法"),
+			"create_path": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "创建时的请求路径，相对于CMP主接口",
+			},
+			"create_body": apiRequestBodySchema("创建时的JSON请求体"),
+			"read_method": apiRequestMethodSchema(http.MethodGet, "读取时的请求方法"),
+			"read_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "读取时的请求路径，为空时不读取远端状态",
+			},
+			"read_body":     apiRequestBodySchema("读取时的JSON请求体"),
+			"update_method": apiRequestMethodSchema(http.MethodPut, "更新时的请求方法"),
+			"update_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "更新时的请求路径，为空时修改创建参数将重建资源",
+			},
+			"update_body":    apiRequestBodySchema("更新时的JSON请求体"),
+			"destroy_method": apiRequestMethodSchema(http.MethodDelete, "删除时的请求方法"),
+			"destroy_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "删除时的请求路径，为空时仅从状态中移除",
+			},
+			"destroy_body": apiRequestBodySchema("删除时的JSON请求体"),
+			"id_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "$.id",
+				ForceNew:    true,
+				Description: "从创建响应中提取资源ID的表达式，如`$.data.id`",
+			},
+			"state_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "$",
+				Description: "从创建或读取响应中提取`state`的表达式，如`$.data`",
+			},
+			"state": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "提取的远端状态，字符串原样返回，其它值为JSON",
+			},
+			"create_response": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "创建时的响应内容",
+			},
+		},
+	}
+}
+
+func apiRequestMethodSchema(method, description string) *schema.Schema {
+	return &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		Default:      method,
+		ValidateFunc: validation.StringInSlice(apiRequestMethods, true),
+		Description:  description,
+	}
+}
+
+func apiRequestBodySchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		ValidateFunc: validation.StringIsJSON,
+		Description:  description,
+	}
+}
+
+func resourceCmpApiRequestCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || d.Get("update_path").(string) != "" {
+		return nil
+	}
+	// Without an update endpoint the object can only be changed by creating it again.
+	for _, key := range []string{"create_method", "create_path", "create_body"} {
+		if d.HasChange(key) {
+			if err := d.ForceNew(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func resourceCmpApiRequestCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	method := d.Get("create_method").(string)
+	path := d.Get("create_path").(string)
+	content, err := client.cmpClient.Request(ctx, method, path, d.Get("create_body").(string))
+	if err != nil {
+		return diagErrorf("[CMP] Unable to %s %s, got error: %s", method, path, err)
+	}
+
+	id, err := utils.JSONPathString([]byte(content), d.Get("id_path").(string))
+	if err != nil || id == "" {
+		return diagErrorf("[CMP] Unable to extract ID from response %s: %v", content, err)
+	}
+
+	d.SetId(id)
+	d.Set("create_response", utils.Redact(content))
+	if diags := setApiRequestState(d, content); diags != nil {
+		return diags
+	}
+
+	tflog.Debug(ctx, "[CMP] Created an API object successfully", utils.RedactFields(map[string]interface{}{
+		"method": method,
+		"path":   path,
+		"id":     id,
+	}))
+
+	return resourceCmpApiRequestRead(ctx, d, meta)
+}
+
+func resourceCmpApiRequestRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	path := d.Get("read_path").(string)
+	if path == "" {
+		return nil
+	}
+
+	method := d.Get("read_method").(string)
+	content, err := client.cmpClient.Request(ctx, method, withApiRequestId(path, d.Id()), withApiRequestId(d.Get("read_body").(string), d.Id()))
+	if err != nil {
+		var respErr *cmp.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+			tflog.Warn(ctx, "[CMP] API object not found, removing from state", map[string]interface{}{"id": d.Id()})
+			d.SetId("")
+			return nil
+		}
+		return diagErrorf("[CMP] Unable to %s %s, got error: %s", method, path, err)
+	}
+
+	return setApiRequestState(d, content)
+}
+
+func resourceCmpApiRequestUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	path := d.Get("update_path").(string)
+	if path != "" && d.HasChanges("update_method", "update_path", "update_body", "create_body") {
+		method := d.Get("update_method").(string)
+		_, err := client.cmpClient.Request(ctx, method, withApiRequestId(path, d.Id()), withApiRequestId(d.Get("update_body").(string), d.Id()))
+		if err != nil {
+			return diagErrorf("[CMP] Unable to %s %s, got error: %s", method, path, err)
+		}
+		tflog.Debug(ctx, "[CMP] Updated an API object successfully", map[string]interface{}{"id": d.Id()})
+	}
+
+	return resourceCmpApiRequestRead(ctx, d, meta)
+}
+
+func resourceCmpApiRequestDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	path := d.Get("destroy_path").(string)
+	if path == "" {
+		return nil
+	}
+
+	method := d.Get("destroy_method").(string)
+	_, err := client.cmpClient.Request(ctx, method, withApiRequestId(path, d.Id()), withApiRequestId(d.Get("destroy_body").(string), d.Id()))
+	if err != nil {
+		var respErr *cmp.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return diagErrorf("[CMP] Unable to %s %s, got error: %s", method, path, err)
+	}
+
+	tflog.Debug(ctx, "[CMP] Deleted an API object successfully", map[string]interface{}{"id": d.Id()})
+	return nil
+}
+
+func setApiRequestState(d *schema.ResourceData, content string) diag.Diagnostics {
+	state, err := utils.JSONPathString([]byte(content), d.Get("state_path").(string))
+	if err != nil {
+		return diagErrorf("[CMP] Unable to extract state from response %s: %s", content, err)
+	}
+	d.Set("state", utils.Redact(state))
+	return nil
+}
+
+func withApiRequestId(s, id string) string {
+	return strings.ReplaceAll(s, apiRequestIdPlaceholder, id)
+}
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestApiRequestResource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testApiRequestResourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("bingo_cmp_api_request.dev", "id"),
+					resource.TestCheckResourceAttrSet("bingo_cmp_api_request.dev", "create_response"),
+					resource.TestCheckResourceAttr("bingo_cmp_api_request.dev", "state", "success"),
+				),
+			},
+		},
+	})
+}
+
+func testApiRequestResourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_api_request" "dev" {
+  create_path = "api/command/sendCommand"
+  create_body = jsonencode({
+    name        = "terraform-api-request"
+    content     = "pwd"
+    hostType    = "1"
+    instanceIds = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+    description = "Created by terraform-provider-bingo"
+  })
+  id_path = "$.recordId"
+
+  read_method = "POST"
+  read_path   = "api/getEntity"
+  read_body   = jsonencode({
+    conStr = "deploy"
+    sqlId  = "command.selectRecordById"
+    params = { id = "{id}" }
+  })
+  state_path = "$.status"
+}
+`)
+}
@@ -3,16 +3,23 @@ package provider
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	"terraform-provider-bingo/internal/pkg/cmp"
+	"terraform-provider-bingo/utils"
 )
 
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 func resourceCmpCommand() *schema.Resource {
 	return &schema.Resource{
 		// This description is used by the documentation generator and the language server.
@@ -23,6 +30,11 @@ func resourceCmpCommand() *schema.Resource {
 		UpdateContext: resourceCmpCommandUpdate,
 		DeleteContext: resourceCmpCommandDelete,
 
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Read:   schema.DefaultTimeout(30 * time.Minute),
+		},
+
 		Schema: map[string]*schema.Schema{
 			"host_type": {
 				Type:        schema.TypeString,
@@ -30,15 +42,68 @@ func resourceCmpCommand() *schema.Resource {
 				Description: "宿主机类型，1:虚拟机,2:物理机",
 			},
 			"content": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"content", "sensitive_content", "script_id"},
+				Description:  "命令内容",
+			},
+			"sensitive_content": {
 				Type:        schema.TypeString,
-				Required:    true,
-				Description: "命令内容",
+				Optional:    true,
+				Sensitive:   true,
+				Description: "敏感命令内容，不会在计划及日志中明文显示，与`content`二选一",
+			},
+			"script_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "执行脚本库中的脚本，与`content`、`sensitive_content`三选一",
+			},
+			"script_version": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				RequiredWith: []string{"script_id"},
+				Description:  "脚本版本，为空时使用最新版本",
+			},
+			"params": {
+				Type:         schema.TypeMap,
+				Optional:     true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				RequiredWith: []string{"script_id"},
+				Description:  "脚本参数，未传入的参数使用脚本中的默认值",
+			},
+			"sensitive_environment": {
+				Type:         schema.TypeMap,
+				Optional:     true,
+				Sensitive:    true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				ValidateFunc: validateEnvironmentNames,
+				Description:  "敏感环境变量，执行命令前导出，不会在计划及日志中明文显示",
 			},
 			"instance_ids": {
 				Type:        schema.TypeString,
 				Required:    true,
 				Description: "实例编号，多个用逗号分割",
 			},
+			"skip_unreachable": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "跳过代理不在线的实例，为`false`时存在不在线的实例将直接报错",
+			},
+			"skip_agent_check": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ConflictsWith: []string{"skip_unreachable"},
+				Description:   "下发前不检查实例的代理状态，代理不在线时指令将一直等待至超时",
+			},
+			"unreachable_instance_ids": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "下发时代理不在线的实例编号，多个用逗号分割",
+			},
 			"record_id": {
 				Type:        schema.TypeString,
 				Computed:    true,
@@ -65,12 +130,47 @@ func resourceCmpCommandCreate(ctx context.Context, d *schema.ResourceData, meta
 	input.HostType = d.Get("host_type").(string)
 	input.Name = "terraform-deploy-" + time.Now().Format("20060102150405")
 	input.Description = "Created by `terraform-provider-bingo`"
-	input.Content = d.Get("content").(string)
 	input.InstanceIds = d.Get("instance_ids").(string)
 
-	output, err := client.cmpClient.CreateCommand(input)
+	content, err := commandContent(ctx, client, d)
+	if err != nil {
+		return diagErrorf("[CMP] Unable to build command content, got error: %s", err)
+	}
+	input.Content = content
+
+	var diags diag.Diagnostics
+
+	// Hold the instances until the command finishes, so commands on the same host never overlap.
+	unlock, err := client.locker.Lock(ctx, splitInstanceIds(input.InstanceIds))
 	if err != nil {
-		return diag.Errorf(fmt.Sprintf("[CMP] Unable to create command, got error: %s", err))
+		return diagErrorf("[CMP] Waiting for instances (%s) : %s", input.InstanceIds, err)
+	}
+	defer unlock()
+
+	// The agents are checked once the instances are held, an agent may go offline while waiting for them.
+	d.Set("unreachable_instance_ids", "")
+	if !d.Get("skip_agent_check").(bool) {
+		reachable, unreachable, err := checkAgents(ctx, client.cmpClient, input.HostType, splitInstanceIds(input.InstanceIds))
+		if err != nil {
+			return diagErrorf("[CMP] Unable to check agents of instances (%s), got error: %s", input.InstanceIds, err)
+		}
+		d.Set("unreachable_instance_ids", strings.Join(unreachableIds(unreachable), ","))
+		if len(unreachable) > 0 {
+			if !d.Get("skip_unreachable").(bool) || len(reachable) == 0 {
+				return diagErrorf("[CMP] Agents of instances are unreachable: %s", joinUnreachable(unreachable))
+			}
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "[CMP] Skipped instances with unreachable agents",
+				Detail:   joinUnreachable(unreachable),
+			})
+			input.InstanceIds = strings.Join(reachable, ",")
+		}
+	}
+
+	output, err := client.cmpClient.CreateCommand(ctx, input)
+	if err != nil {
+		return append(diags, diagErrorf("[CMP] Unable to create command, got error: %s", err)...)
 	}
 
 	d.SetId(output.RecordId)
@@ -78,30 +178,34 @@ func resourceCmpCommandCreate(ctx context.Context, d *schema.ResourceData, meta
 	d.Set("task_id", output.TaskId)
 	d.Set("status", output.Status)
 
+	result, err := waitForCommand(ctx, client, output.RecordId, d.Timeout(schema.TimeoutCreate))
+	if result != nil {
+		d.Set("status", result.Status)
+	}
+	if err != nil {
+		return append(diags, diagErrorf("[CMP] Waiting for command (%s) : %s", output.RecordId, err)...)
+	}
+
 	// write logs using the tflog package
 	// see https://pkg.go.dev/github.com/hashicorp/terraform-plugin-log/tflog
 	// for more information
-	tflog.Debug(ctx, "Sent a command successfully", map[string]interface{}{
+	tflog.Debug(ctx, "Sent a command successfully", utils.RedactFields(map[string]interface{}{
 		"input":  input,
 		"output": output,
-	})
+	}))
 
-	return nil
+	return diags
 }
 
 func resourceCmpCommandRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	client := meta.(*bingoCloudClient)
 
-	input := &cmp.DescribeCommandInput{
-		ConStr: "deploy",
-		SqlId:  "command.selectRecordById",
-		Params: struct {
-			Id string `json:"id"`
-		}{Id: d.Id()},
-	}
-	output, err := client.cmpClient.DescribeCommand(input)
+	// The output holds the content, secrets registered on create are gone in a new provider process.
+	registerCommandSecrets(d)
+
+	output, err := client.poller.Describe(ctx, d.Id())
 	if err != nil {
-		return diag.Errorf(fmt.Sprintf("[CMP] Unable to read command, got error: %s", err))
+		return diagErrorf("[CMP] Unable to read command, got error: %s", err)
 	}
 
 	d.SetId(output.Id)
@@ -109,24 +213,15 @@ func resourceCmpCommandRead(ctx context.Context, d *schema.ResourceData, meta in
 	d.Set("task_id", output.TaskId)
 	d.Set("status", output.Status)
 
-	stateConf := &resource.StateChangeConf{
-		Pending:      []string{cmp.CommandStatusNew, cmp.CommandStatusDeploying},
-		Target:       []string{cmp.CommandStatusSuccess},
-		Refresh:      refreshCommandStatus(client.cmpClient, input, cmp.CommandStatusFailed),
-		Timeout:      30 * time.Minute,
-		Delay:        1 * time.Minute,
-		PollInterval: 20 * time.Second,
-	}
-
-	_, err = stateConf.WaitForStateContext(ctx)
+	_, err = waitForCommand(ctx, client, output.Id, d.Timeout(schema.TimeoutRead))
 	if err != nil {
-		return diag.Errorf(fmt.Sprintf("[CMP] Waiting for command (%s) : %s", output.Id, err))
+		return diagErrorf("[CMP] Waiting for command (%s) : %s", output.Id, err)
 	}
 
-	tflog.Debug(ctx, "[CMP] Executed a command successfully", map[string]interface{}{
-		"input":  input,
-		"output": output,
-	})
+	tflog.Debug(ctx, "[CMP] Executed a command successfully", utils.RedactFields(map[string]interface{}{
+		"record_id": d.Id(),
+		"output":    output,
+	}))
 
 	return nil
 }
@@ -145,14 +240,32 @@ func resourceCmpCommandDelete(ctx context.Context, d *schema.ResourceData, meta
 	return nil
 }
 
-func refreshCommandStatus(cmpClient *cmp.Client, input *cmp.DescribeCommandInput, failState string) resource.StateRefreshFunc {
+// waitForCommand polls the command record through the shared poller until it succeeds, fails or timeout expires.
+func waitForCommand(ctx context.Context, client *bingoCloudClient, recordId string, timeout time.Duration) (*cmp.DescribeCommandOutput, error) {
+	stateConf := &resource.StateChangeConf{
+		Pending:      []string{cmp.CommandStatusNew, cmp.CommandStatusDeploying},
+		Target:       []string{cmp.CommandStatusSuccess},
+		Refresh:      refreshCommandStatus(ctx, client, recordId, cmp.CommandStatusFailed),
+		Timeout:      timeout,
+		Delay:        1 * time.Minute,
+		PollInterval: 20 * time.Second,
+	}
+
+	result, err := stateConf.WaitForStateContext(ctx)
+	if output, ok := result.(*cmp.DescribeCommandOutput); ok {
+		return output, err
+	}
+	return nil, err
+}
+
+func refreshCommandStatus(ctx context.Context, client *bingoCloudClient, recordId string, failState string) resource.StateRefreshFunc {
 	return func() (interface{}, string, error) {
-		output, err := cmpClient.DescribeCommand(input)
+		output, err := client.poller.Describe(ctx, recordId)
 		if err != nil {
 			return nil, "", err
 		}
 		if output.Status == failState {
-			steps, err := cmpClient.DescribeCommandSteps(&cmp.DescribeCommandStepsInput{
+			steps, err := client.cmpClient.DescribeCommandSteps(ctx, &cmp.DescribeCommandStepsInput{
 				SqlId:  "task.listAllStepsForAgent",
 				ConStr: "deploy",
 				Params: struct {
@@ -167,8 +280,84 @@ func refreshCommandStatus(cmpClient *cmp.Client, input *cmp.DescribeCommandInput
 			if len(steps) != 1 {
 				return nil, "", fmt.Errorf("command step not found (%s)", output.TaskId)
 			}
-			return output, output.Status, fmt.Errorf("failed to reach target state. Reason: %s", steps[0].StepLog)
+			return output, output.Status, fmt.Errorf("failed to reach target state. Reason: %s", utils.Redact(steps[0].StepLog))
 		}
 		return output, output.Status, nil
 	}
 }
+
+// splitInstanceIds splits the comma separated `instance_ids` attribute.
+func splitInstanceIds(instanceIds string) []string {
+	var ids []string
+	for _, id := range strings.Split(instanceIds, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func validateEnvironmentNames(v interface{}, k string) (ws []string, errs []error) {
+	for name := range v.(map[string]interface{}) {
+		if !envNameRegexp.MatchString(name) {
+			errs = append(errs, fmt.Errorf("%q: invalid environment variable name %q", k, name))
+		}
+	}
+	return
+}
+
+// registerCommandSecrets registers `sensitive_content` and the `sensitive_environment` values for redaction,
+// every provider process reading the resource must do so before logging what CMP returns.
+func registerCommandSecrets(d resourceGetter) {
+	content, _ := d.Get("sensitive_content").(string)
+	utils.RegisterSecret(content)
+	env, _ := d.Get("sensitive_environment").(map[string]interface{})
+	for _, v := range env {
+		utils.RegisterSecret(v.(string), shellQuote(v.(string)))
+	}
+}
+
+// commandContent builds the script to send, exporting `sensitive_environment` ahead of the content
+// or of the library script. Sensitive values are registered for redaction before they can reach any
+// log or diagnostic.
+func commandContent(ctx context.Context, client *bingoCloudClient, d *schema.ResourceData) (string, error) {
+	registerCommandSecrets(d)
+
+	content := d.Get("content").(string)
+	if v, ok := d.GetOk("sensitive_content"); ok {
+		content = v.(string)
+	}
+	if v, ok := d.GetOk("script_id"); ok {
+		script, err := client.cmpClient.DescribeScript(ctx, v.(string), d.Get("script_version").(int))
+		if err != nil {
+			return "", err
+		}
+		if script == nil {
+			return "", fmt.Errorf("script %s version %d not found", v, d.Get("script_version"))
+		}
+		if content, err = renderScript(script, d.Get("params").(map[string]interface{})); err != nil {
+			return "", err
+		}
+		d.Set("script_version", script.Version)
+	}
+
+	env := d.Get("sensitive_environment").(map[string]interface{})
+	if len(env) == 0 {
+		return content, nil
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		v := env[k].(string)
+		fmt.Fprintf(&b, "export %s=%s\n", k, shellQuote(v))
+	}
+	b.WriteString(content)
+
+	return b.String(), nil
+}
@@ -2,9 +2,12 @@ package provider
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+
+	"terraform-provider-bingo/utils"
 )
 
 func TestCommandResource(t *testing.T) {
@@ -17,7 +20,7 @@ func TestCommandResource(t *testing.T) {
 				Check: resource.ComposeTestCheckFunc(
 					resource.TestCheckResourceAttr("bingo_cmp_command.dev", "host_type", "1"),
 					resource.TestCheckResourceAttr("bingo_cmp_command.dev", "content", "pwd"),
-					resource.TestCheckResourceAttr("bingo_cmp_command.dev", "status", "new"),
+					resource.TestCheckResourceAttr("bingo_cmp_command.dev", "status", "success"),
 					resource.TestCheckResourceAttr("bingo_cmp_command.dev", "instance_ids", "c0dea473-cfc0-49a7-830e-a7edc8f1125d"),
 				),
 			},
@@ -25,6 +28,18 @@ func TestCommandResource(t *testing.T) {
 	})
 }
 
+func TestRegisterCommandSecrets(t *testing.T) {
+	registerCommandSecrets(mapGetter{
+		"sensitive_content":     "echo s3cr3t-content",
+		"sensitive_environment": map[string]interface{}{"TOKEN": "it's-a-token"},
+	})
+
+	log := utils.Redact("export TOKEN='it'\"'\"'s-a-token'\necho s3cr3t-content")
+	if strings.Contains(log, "s3cr3t") || strings.Contains(log, "a-token") {
+		t.Errorf("secrets were not redacted: %s", log)
+	}
+}
+
 func testCommandResourceConfig() string {
 	return fmt.Sprintf(`
 provider "bingo" {
@@ -0,0 +1,361 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+// fileChunkSize is the size of the base64 text sent by one command, larger files are sent in several commands.
+const fileChunkSize = 32 * 1024
+
+// resourceGetter is implemented by both schema.ResourceData and schema.ResourceDiff.
+type resourceGetter interface {
+	Get(key string) interface{}
+}
+
+func resourceCmpFile() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "通过CMP指令向实例分发文件（仅支持Linux），读取时校验各实例上文件的SHA256以发现漂移",
+
+		CreateContext: resourceCmpFileCreate,
+		ReadContext:   resourceCmpFileRead,
+		UpdateContext: resourceCmpFileUpdate,
+		DeleteContext: resourceCmpFileDelete,
+
+		CustomizeDiff: resourceCmpFileCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Read:   schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"host_type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.HostTypeVirtualMachine, cmp.HostTypePhysical}, false),
+				Description:  "宿主机类型，1:虚拟机,2:物理机",
+			},
+			"instance_ids": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "实例编号，多个用逗号分割",
+			},
+			"path": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringMatch(regexp.MustCompile(`^/`), "must be an absolute path"),
+				Description:  "文件的绝对路径",
+			},
+			"content": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"content", "content_base64", "source"},
+				Description:  "文件内容",
+			},
+			"content_base64": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsBase64,
+				Description:  "Base64编码的文件内容，用于二进制文件",
+			},
+			"source": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "本地文件路径，分发该文件的内容",
+			},
+			"owner": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "文件属主",
+			},
+			"group": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "文件属组",
+			},
+			"mode": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "0644",
+				ValidateFunc: validation.StringMatch(regexp.MustCompile(`^[0-7]{3,4}$`), "must be an octal file mode"),
+				Description:  "文件权限",
+			},
+			"checksum": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "文件内容的SHA256",
+			},
+			"instance_checksums": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "各实例上文件的SHA256，文件不存在时为空",
+			},
+		},
+	}
+}
+
+func resourceCmpFileCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("content") || !d.NewValueKnown("content_base64") || !d.NewValueKnown("source") {
+		if err := d.SetNewComputed("checksum"); err != nil {
+			return err
+		}
+		return d.SetNewComputed("instance_checksums")
+	}
+	content, err := fileContent(d)
+	if err != nil {
+		return err
+	}
+	checksum := sha256Hex(content)
+	if err := d.SetNew("checksum", checksum); err != nil {
+		return err
+	}
+
+	if !d.NewValueKnown("instance_ids") {
+		return d.SetNewComputed("instance_checksums")
+	}
+
+	// The desired state is the file on every instance, a differing observed checksum shows up as drift.
+	expected := map[string]interface{}{}
+	for _, id := range splitInstanceIds(d.Get("instance_ids").(string)) {
+		expected[id] = checksum
+	}
+	return d.SetNew("instance_checksums", expected)
+}
+
+func resourceCmpFileCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	content, err := fileContent(d)
+	if err != nil {
+		return diagErrorf("[CMP] Unable to read file content: %s", err)
+	}
+
+	instanceIds := splitInstanceIds(d.Get("instance_ids").(string))
+	checksums, err := uploadFile(ctx, client, d, instanceIds, content, d.Timeout(schema.TimeoutCreate))
+	if err != nil {
+		return diagErrorf("[CMP] Unable to upload file %s, got error: %s", d.Get("path"), err)
+	}
+
+	d.SetId(fmt.Sprintf("%s:%s", d.Get("host_type"), d.Get("path")))
+	d.Set("checksum", sha256Hex(content))
+	d.Set("instance_checksums", checksums)
+
+	return nil
+}
+
+func resourceCmpFileRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	path := shellQuote(d.Get("path").(string))
+	results, err := runCommand(ctx, client, &commandRun{
+		HostType:    d.Get("host_type").(string),
+		InstanceIds: splitInstanceIds(d.Get("instance_ids").(string)),
+		Name:        "terraform-file-read",
+		Content: fmt.Sprintf("if [ -f %s ]; then %s; else %s; fi",
+			path, scriptMarker("checksum", fmt.Sprintf("$(sha256sum %s | cut -d' ' -f1)", path)), scriptMarker("checksum", "")),
+		Timeout: d.Timeout(schema.TimeoutRead),
+	})
+	if err != nil {
+		return diagErrorf("[CMP] Unable to read file %s, got error: %s", d.Get("path"), err)
+	}
+
+	return observeInstances(d, results, []string{"instance_checksums"}, fmt.Sprintf("file %s", d.Get("path")),
+		func(result *commandResult) (map[string]string, bool) {
+			checksum, ok := result.Marker("checksum")
+			return map[string]string{"instance_checksums": checksum}, result.Succeeded() && ok
+		})
+}
+
+func resourceCmpFileUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	content, err := fileContent(d)
+	if err != nil {
+		return diagErrorf("[CMP] Unable to read file content: %s", err)
+	}
+	checksum := sha256Hex(content)
+
+	// Only upload to the instances whose file differs, unless the file itself changed.
+	instanceIds, removed, targets := updateTargets(d, []string{"content", "content_base64", "source", "owner", "group", "mode"},
+		priorDrift(d, "instance_checksums", checksum))
+	if len(removed) > 0 {
+		if err := removeFile(ctx, client, d, removed, d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return diagErrorf("[CMP] Unable to remove file %s, got error: %s", d.Get("path"), err)
+		}
+	}
+
+	checksums := map[string]interface{}{}
+	for _, id := range instanceIds {
+		checksums[id] = checksum
+	}
+	if len(targets) > 0 {
+		uploaded, err := uploadFile(ctx, client, d, targets, content, d.Timeout(schema.TimeoutUpdate))
+		if err != nil {
+			return diagErrorf("[CMP] Unable to upload file %s, got error: %s", d.Get("path"), err)
+		}
+		for id, v := range uploaded {
+			checksums[id] = v
+		}
+	}
+
+	d.Set("checksum", checksum)
+	d.Set("instance_checksums", checksums)
+
+	tflog.Debug(ctx, "[CMP] Updated a file successfully", map[string]interface{}{
+		"path":    d.Get("path"),
+		"targets": targets,
+	})
+
+	return nil
+}
+
+func resourceCmpFileDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	if err := removeFile(ctx, client, d, splitInstanceIds(d.Get("instance_ids").(string)), d.Timeout(schema.TimeoutDelete)); err != nil {
+		return diagErrorf("[CMP] Unable to remove file %s, got error: %s", d.Get("path"), err)
+	}
+	return nil
+}
+
+// fileContent returns the content to deliver from `content`, `content_base64` or `source`.
+func fileContent(d resourceGetter) ([]byte, error) {
+	if v := d.Get("content_base64").(string); v != "" {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	if v := d.Get("source").(string); v != "" {
+		return os.ReadFile(v)
+	}
+	return []byte(d.Get("content").(string)), nil
+}
+
+// uploadFile delivers content to the instances as base64 chunks and returns the checksum each one reports.
+func uploadFile(ctx context.Context, client *bingoCloudClient, d *schema.ResourceData, instanceIds []string, content []byte, timeout time.Duration) (map[string]interface{}, error) {
+	path := d.Get("path").(string)
+	encoded := base64.StdEncoding.EncodeToString(content)
+	chunks := splitChunks(encoded, fileChunkSize)
+
+	for i, chunk := range chunks[:len(chunks)-1] {
+		script := "set -e\n" + fileChunkScript(path, chunk, i > 0)
+		if err := runOnAll(ctx, client, d.Get("host_type").(string), instanceIds, "terraform-file-chunk", script, timeout); err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := runCommand(ctx, client, &commandRun{
+		HostType:    d.Get("host_type").(string),
+		InstanceIds: instanceIds,
+		Name:        "terraform-file-upload",
+		Content:     fileInstallScript(d, chunks[len(chunks)-1], len(chunks) > 1),
+		Timeout:     timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := failedResults(results); err != nil {
+		return nil, err
+	}
+
+	checksums := map[string]interface{}{}
+	for id, result := range results {
+		checksums[id], _ = result.Marker("checksum")
+	}
+	return checksums, nil
+}
+
+// fileChunkScript writes a base64 chunk of the file next to it, appending unless it is the first chunk.
+func fileChunkScript(path, chunk string, appendChunk bool) string {
+	redirect := ">"
+	if appendChunk {
+		redirect = ">>"
+	}
+	return fmt.Sprintf("mkdir -p \"$(dirname %s)\"\nprintf '%%s' %s %s %s\n",
+		shellQuote(path), shellQuote(chunk), redirect, shellQuote(path+".tf-bingo.b64"))
+}
+
+// fileInstallScript appends the last chunk, decodes the file, applies owner and mode and moves it into place.
+func fileInstallScript(d resourceGetter, lastChunk string, appendChunk bool) string {
+	path := d.Get("path").(string)
+	encodedPath, tmpPath := shellQuote(path+".tf-bingo.b64"), shellQuote(path+".tf-bingo.tmp")
+
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	b.WriteString(fileChunkScript(path, lastChunk, appendChunk))
+	fmt.Fprintf(&b, "base64 -d %s > %s\n", encodedPath, tmpPath)
+	fmt.Fprintf(&b, "rm -f %s\n", encodedPath)
+
+	owner, group := d.Get("owner").(string), d.Get("group").(string)
+	switch {
+	case owner != "" && group != "":
+		fmt.Fprintf(&b, "chown %s %s\n", shellQuote(owner+":"+group), tmpPath)
+	case owner != "":
+		fmt.Fprintf(&b, "chown %s %s\n", shellQuote(owner), tmpPath)
+	case group != "":
+		fmt.Fprintf(&b, "chgrp %s %s\n", shellQuote(group), tmpPath)
+	}
+	fmt.Fprintf(&b, "chmod %s %s\n", d.Get("mode").(string), tmpPath)
+	fmt.Fprintf(&b, "mv -f %s %s\n", tmpPath, shellQuote(path))
+	b.WriteString(scriptMarker("checksum", fmt.Sprintf("$(sha256sum %s | cut -d' ' -f1)", shellQuote(path))))
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+func removeFile(ctx context.Context, client *bingoCloudClient, d *schema.ResourceData, instanceIds []string, timeout time.Duration) error {
+	path := d.Get("path").(string)
+	script := fmt.Sprintf("rm -f %s %s %s", shellQuote(path), shellQuote(path+".tf-bingo.b64"), shellQuote(path+".tf-bingo.tmp"))
+	return runOnAll(ctx, client, d.Get("host_type").(string), instanceIds, "terraform-file-delete", script, timeout)
+}
+
+// runOnAll runs the script and fails unless it succeeded on every instance.
+func runOnAll(ctx context.Context, client *bingoCloudClient, hostType string, instanceIds []string, name, script string, timeout time.Duration) error {
+	results, err := runCommand(ctx, client, &commandRun{
+		HostType:    hostType,
+		InstanceIds: instanceIds,
+		Name:        name,
+		Content:     script,
+		Timeout:     timeout,
+	})
+	if err != nil {
+		return err
+	}
+	return failedResults(results)
+}
+
+func splitChunks(s string, size int) []string {
+	if len(s) == 0 {
+		return []string{""}
+	}
+	var chunks []string
+	for len(s) > size {
+		chunks = append(chunks, s[:size])
+		s = s[size:]
+	}
+	return append(chunks, s)
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestFileResource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testFileResourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_file.dev", "path", "/tmp/terraform-bingo.conf"),
+					resource.TestCheckResourceAttr("bingo_cmp_file.dev", "checksum", sha256Hex([]byte("key = value\n"))),
+					resource.TestCheckResourceAttr("bingo_cmp_file.dev", "instance_checksums.c0dea473-cfc0-49a7-830e-a7edc8f1125d", sha256Hex([]byte("key = value\n"))),
+				),
+			},
+		},
+	})
+}
+
+// mapGetter stands in for schema.ResourceData when rendering scripts.
+type mapGetter map[string]interface{}
+
+func (its mapGetter) Get(key string) interface{} {
+	if v, ok := its[key]; ok {
+		return v
+	}
+	return ""
+}
+
+func TestFileInstallScript(t *testing.T) {
+	if _, err := exec.LookPath("sha256sum"); err != nil {
+		t.Skip("sha256sum is not available")
+	}
+
+	path := filepath.Join(t.TempDir(), "conf.d", "it's.conf")
+	content := []byte(strings.Repeat("line with 'quotes'\n", 100))
+	chunks := splitChunks(base64.StdEncoding.EncodeToString(content), 1000)
+
+	d := mapGetter{"path": path, "mode": "0600"}
+	var script strings.Builder
+	for i, chunk := range chunks[:len(chunks)-1] {
+		script.WriteString(fileChunkScript(path, chunk, i > 0))
+	}
+	script.WriteString(fileInstallScript(d, chunks[len(chunks)-1], len(chunks) > 1))
+
+	output, err := exec.Command("sh", "-c", script.String()).CombinedOutput()
+	if err != nil {
+		t.Fatalf("script failed: %s\n%s", err, output)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil || string(got) != string(content) {
+		t.Fatalf("unexpected file content: %v", err)
+	}
+	result := &commandResult{Log: string(output)}
+	if checksum, ok := result.Marker("checksum"); !ok || checksum != sha256Hex(content) {
+		t.Fatalf("unexpected checksum marker in %s", output)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected mode: %s", info.Mode())
+	}
+}
+
+func testFileResourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_file" "dev" {
+  host_type    = "1"
+  instance_ids = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  path         = "/tmp/terraform-bingo.conf"
+  content      = "key = value\n"
+  mode         = "0600"
+}
+`)
+}
@@ -0,0 +1,375 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+const hostUserSudoersDir = "/etc/sudoers.d"
+
+var (
+	hostUserNameRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+	singleLineRegexp   = regexp.MustCompile(`^[^\r\n]+$`)
+)
+
+func resourceCmpHostUser() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "通过CMP指令管理实例上的本地用户、SSH公钥及sudo规则（仅支持Linux）。" +
+			"读取时校验各实例上用户的状态以发现漂移，删除时结束用户的进程并删除用户及其主目录",
+
+		CreateContext: resourceCmpHostUserCreate,
+		ReadContext:   resourceCmpHostUserRead,
+		UpdateContext: resourceCmpHostUserUpdate,
+		DeleteContext: resourceCmpHostUserDelete,
+
+		CustomizeDiff: resourceCmpHostUserCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Read:   schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"host_type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.HostTypeVirtualMachine, cmp.HostTypePhysical}, false),
+				Description:  "宿主机类型，1:虚拟机,2:物理机",
+			},
+			"instance_ids": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "实例编号，多个用逗号分割",
+			},
+			"username": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringMatch(hostUserNameRegexp, "must be a lowercase user name of at most 32 characters"),
+				Description:  "用户名",
+			},
+			"uid": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "用户ID，为空时自动分配",
+			},
+			"groups": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringMatch(hostUserNameRegexp, "must be a group name"),
+				},
+				Description: "附加组，不存在时自动创建，不包括用户的主组",
+			},
+			"shell": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "/bin/bash",
+				ValidateFunc: validation.StringMatch(regexp.MustCompile(`^/[A-Za-z0-9_./-]+$`), "must be an absolute path"),
+				Description:  "登录Shell",
+			},
+			"authorized_keys": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringMatch(singleLineRegexp, "must be a single line"),
+				},
+				Description: "SSH公钥，将完整覆盖用户的`~/.ssh/authorized_keys`，为空时删除该文件",
+			},
+			"sudo_rules": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringMatch(singleLineRegexp, "must be a single line"),
+				},
+				Description: "sudo规则，不含用户名，如`ALL=(ALL) NOPASSWD: ALL`，写入`/etc/sudoers.d`并经过`visudo`校验，为空时删除",
+			},
+			"instance_checksums": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "各实例上用户状态的SHA256，用户不存在时为空",
+			},
+		},
+	}
+}
+
+func resourceCmpHostUserCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	for _, key := range []string{"instance_ids", "username", "uid", "groups", "shell", "authorized_keys", "sudo_rules"} {
+		if !d.NewValueKnown(key) {
+			return d.SetNewComputed("instance_checksums")
+		}
+	}
+	checksum := hostUserChecksum(d)
+	expected := map[string]interface{}{}
+	for _, id := range splitInstanceIds(d.Get("instance_ids").(string)) {
+		expected[id] = checksum
+	}
+	return d.SetNew("instance_checksums", expected)
+}
+
+func resourceCmpHostUserCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	instanceIds := splitInstanceIds(d.Get("instance_ids").(string))
+	if err := runOnAll(ctx, client, d.Get("host_type").(string), instanceIds, "terraform-user-apply", hostUserApplyScript(d), d.Timeout(schema.TimeoutCreate)); err != nil {
+		return diagErrorf("[CMP] Unable to apply user %s, got error: %s", d.Get("username"), err)
+	}
+
+	d.SetId(fmt.Sprintf("%s:%s", d.Get("host_type"), d.Get("username")))
+
+	checksums := map[string]interface{}{}
+	for _, id := range instanceIds {
+		checksums[id] = hostUserChecksum(d)
+	}
+	d.Set("instance_checksums", checksums)
+
+	return nil
+}
+
+func resourceCmpHostUserRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	results, err := runCommand(ctx, client, &commandRun{
+		HostType:    d.Get("host_type").(string),
+		InstanceIds: splitInstanceIds(d.Get("instance_ids").(string)),
+		Name:        "terraform-user-read",
+		Content:     hostUserReadScript(d),
+		Timeout:     d.Timeout(schema.TimeoutRead),
+	})
+	if err != nil {
+		return diagErrorf("[CMP] Unable to read user %s, got error: %s", d.Get("username"), err)
+	}
+
+	return observeInstances(d, results, []string{"instance_checksums"}, fmt.Sprintf("user %s", d.Get("username")),
+		func(result *commandResult) (map[string]string, bool) {
+			state, ok := hostUserObservedState(result)
+			if !ok || state == "" {
+				return map[string]string{"instance_checksums": ""}, ok
+			}
+			return map[string]string{"instance_checksums": sha256Hex([]byte(state))}, true
+		})
+}
+
+func resourceCmpHostUserUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	hostType := d.Get("host_type").(string)
+	checksum := hostUserChecksum(d)
+	instanceIds, removed, targets := updateTargets(d, []string{"uid", "groups", "shell", "authorized_keys", "sudo_rules"},
+		priorDrift(d, "instance_checksums", checksum))
+	if len(removed) > 0 {
+		if err := runOnAll(ctx, client, hostType, removed, "terraform-user-delete", hostUserRemoveScript(d), d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return diagErrorf("[CMP] Unable to remove user %s, got error: %s", d.Get("username"), err)
+		}
+	}
+	if len(targets) > 0 {
+		if err := runOnAll(ctx, client, hostType, targets, "terraform-user-apply", hostUserApplyScript(d), d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return diagErrorf("[CMP] Unable to apply user %s, got error: %s", d.Get("username"), err)
+		}
+	}
+
+	checksums := map[string]interface{}{}
+	for _, id := range instanceIds {
+		checksums[id] = checksum
+	}
+	d.Set("instance_checksums", checksums)
+
+	tflog.Debug(ctx, "[CMP] Updated a host user successfully", map[string]interface{}{
+		"username": d.Get("username"),
+		"targets":  targets,
+	})
+
+	return nil
+}
+
+func resourceCmpHostUserDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	instanceIds := splitInstanceIds(d.Get("instance_ids").(string))
+	if err := runOnAll(ctx, client, d.Get("host_type").(string), instanceIds, "terraform-user-delete", hostUserRemoveScript(d), d.Timeout(schema.TimeoutDelete)); err != nil {
+		return diagErrorf("[CMP] Unable to remove user %s, got error: %s", d.Get("username"), err)
+	}
+	return nil
+}
+
+func hostUserSudoersPath(username string) string {
+	return fmt.Sprintf("%s/terraform-bingo-%s", hostUserSudoersDir, username)
+}
+
+func hostUserGroups(d resourceGetter) []string {
+	var groups []string
+	if set, ok := d.Get("groups").(*schema.Set); ok {
+		for _, v := range set.List() {
+			groups = append(groups, v.(string))
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+func hostUserLines(d resourceGetter, key, prefix string) string {
+	var b strings.Builder
+	for _, v := range d.Get(key).([]interface{}) {
+		b.WriteString(prefix + v.(string) + "\n")
+	}
+	return b.String()
+}
+
+func hostUserAuthorizedKeys(d resourceGetter) string {
+	return hostUserLines(d, "authorized_keys", "")
+}
+
+func hostUserSudoers(d resourceGetter) string {
+	return hostUserLines(d, "sudo_rules", d.Get("username").(string)+" ")
+}
+
+// hostUserState renders the state of the user as hostUserReadScript reports it.
+func hostUserState(d resourceGetter) string {
+	uid := ""
+	if v := d.Get("uid").(int); v > 0 {
+		uid = fmt.Sprint(v)
+	}
+	fileChecksum := func(content string) string {
+		if content == "" {
+			return ""
+		}
+		return sha256Hex([]byte(content))
+	}
+	return fmt.Sprintf("uid=%s\nshell=%s\ngroups=%s\nauthorized_keys=%s\nsudo=%s\n", uid, d.Get("shell"),
+		strings.Join(hostUserGroups(d), ","), fileChecksum(hostUserAuthorizedKeys(d)), fileChecksum(hostUserSudoers(d)))
+}
+
+func hostUserChecksum(d resourceGetter) string {
+	return sha256Hex([]byte(hostUserState(d)))
+}
+
+// hostUserObservedState decodes the `state` marker, which is empty when the user does not exist.
+func hostUserObservedState(result *commandResult) (string, bool) {
+	if !result.Succeeded() {
+		return "", false
+	}
+	v, ok := result.Marker("state")
+	if !ok {
+		return "", false
+	}
+	state, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return "", false
+	}
+	return string(state), true
+}
+
+func hostUserPrelude(d resourceGetter) string {
+	return fmt.Sprintf("__tf_bingo_user=%s\n__tf_bingo_sudoers=%s\n",
+		shellQuote(d.Get("username").(string)), hostUserSudoersPath(d.Get("username").(string)))
+}
+
+// hostUserReadScript prints the state of the user in the format of hostUserState, the uid is only reported
+// when it is managed.
+func hostUserReadScript(d resourceGetter) string {
+	uid := ""
+	if d.Get("uid").(int) > 0 {
+		uid = `$(id -u "$__tf_bingo_user")`
+	}
+
+	var b strings.Builder
+	b.WriteString(hostUserPrelude(d))
+	b.WriteString("if id -u \"$__tf_bingo_user\" >/dev/null 2>&1; then\n")
+	b.WriteString("  __tf_bingo_home=$(getent passwd \"$__tf_bingo_user\" | cut -d: -f6)\n")
+	b.WriteString("  __tf_bingo_state=$(\n")
+	fmt.Fprintf(&b, "    echo \"uid=%s\"\n", uid)
+	b.WriteString("    echo \"shell=$(getent passwd \"$__tf_bingo_user\" | cut -d: -f7)\"\n")
+	b.WriteString("    echo \"groups=$(id -nG \"$__tf_bingo_user\" | tr ' ' '\\n' | grep -vxF \"$(id -gn \"$__tf_bingo_user\")\" | LC_ALL=C sort | paste -sd, -)\"\n")
+	b.WriteString("    echo \"authorized_keys=$([ -f \"$__tf_bingo_home/.ssh/authorized_keys\" ] && sha256sum < \"$__tf_bingo_home/.ssh/authorized_keys\" | cut -d' ' -f1)\"\n")
+	b.WriteString("    echo \"sudo=$([ -f \"$__tf_bingo_sudoers\" ] && sha256sum < \"$__tf_bingo_sudoers\" | cut -d' ' -f1)\"\n")
+	b.WriteString("  )\n")
+	b.WriteString("  " + scriptMarker("state", "$(printf '%s\\n' \"$__tf_bingo_state\" | base64 | tr -d '\\n')") + "\n")
+	b.WriteString("else\n")
+	b.WriteString("  " + scriptMarker("state", "") + "\n")
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// hostUserApplyScript creates or modifies the user, then replaces its authorized keys and sudo rules.
+func hostUserApplyScript(d resourceGetter) string {
+	groups := hostUserGroups(d)
+
+	var opts []string
+	opts = append(opts, "-s", shellQuote(d.Get("shell").(string)))
+	if uid := d.Get("uid").(int); uid > 0 {
+		opts = append(opts, "-u", fmt.Sprint(uid))
+	}
+	useradd := append([]string{"useradd", "-m"}, opts...)
+	usermod := append([]string{"usermod"}, opts...)
+	usermod = append(usermod, "-G", shellQuote(strings.Join(groups, ",")))
+	if len(groups) > 0 {
+		useradd = append(useradd, "-G", shellQuote(strings.Join(groups, ",")))
+	}
+
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	b.WriteString(hostUserPrelude(d))
+	for _, group := range groups {
+		fmt.Fprintf(&b, "getent group %[1]s >/dev/null || groupadd %[1]s\n", shellQuote(group))
+	}
+	fmt.Fprintf(&b, "if id -u \"$__tf_bingo_user\" >/dev/null 2>&1; then %s \"$__tf_bingo_user\"; else %s \"$__tf_bingo_user\"; fi\n",
+		strings.Join(usermod, " "), strings.Join(useradd, " "))
+	b.WriteString("__tf_bingo_home=$(getent passwd \"$__tf_bingo_user\" | cut -d: -f6)\n")
+	b.WriteString("__tf_bingo_group=$(id -gn \"$__tf_bingo_user\")\n")
+
+	if keys := hostUserAuthorizedKeys(d); keys != "" {
+		b.WriteString("install -d -m 0700 -o \"$__tf_bingo_user\" -g \"$__tf_bingo_group\" \"$__tf_bingo_home/.ssh\"\n")
+		fmt.Fprintf(&b, "printf '%%s' %s | base64 -d > \"$__tf_bingo_home/.ssh/authorized_keys\"\n", base64.StdEncoding.EncodeToString([]byte(keys)))
+		b.WriteString("chmod 0600 \"$__tf_bingo_home/.ssh/authorized_keys\"\n")
+		b.WriteString("chown \"$__tf_bingo_user:$__tf_bingo_group\" \"$__tf_bingo_home/.ssh/authorized_keys\"\n")
+	} else {
+		b.WriteString("rm -f \"$__tf_bingo_home/.ssh/authorized_keys\"\n")
+	}
+
+	if sudoers := hostUserSudoers(d); sudoers != "" {
+		// A broken sudoers file locks everyone out of sudo, validate it before putting it in place.
+		fmt.Fprintf(&b, "mkdir -p %s\n", hostUserSudoersDir)
+		fmt.Fprintf(&b, "printf '%%s' %s | base64 -d > \"$__tf_bingo_sudoers.tmp\"\n", base64.StdEncoding.EncodeToString([]byte(sudoers)))
+		b.WriteString("chmod 0440 \"$__tf_bingo_sudoers.tmp\"\n")
+		b.WriteString("visudo -cf \"$__tf_bingo_sudoers.tmp\" || { rm -f \"$__tf_bingo_sudoers.tmp\"; exit 1; }\n")
+		b.WriteString("mv -f \"$__tf_bingo_sudoers.tmp\" \"$__tf_bingo_sudoers\"\n")
+	} else {
+		b.WriteString("rm -f \"$__tf_bingo_sudoers\"\n")
+	}
+	return b.String()
+}
+
+// hostUserRemoveScript ends the processes of the user and removes it with its home and sudo rules.
+func hostUserRemoveScript(d resourceGetter) string {
+	var b strings.Builder
+	b.WriteString(hostUserPrelude(d))
+	b.WriteString("rm -f \"$__tf_bingo_sudoers\"\n")
+	b.WriteString("if id -u \"$__tf_bingo_user\" >/dev/null 2>&1; then\n")
+	b.WriteString("  pkill -KILL -u \"$__tf_bingo_user\" || true\n")
+	// userdel fails on a missing mail spool after removing the user, so check whether it is gone instead.
+	b.WriteString("  userdel -r \"$__tf_bingo_user\" || true\n")
+	b.WriteString("  ! id -u \"$__tf_bingo_user\" >/dev/null 2>&1\n")
+	b.WriteString("fi\n")
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestHostUserResource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testHostUserResourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_host_user.dev", "username", "alice"),
+					resource.TestCheckResourceAttrSet("bingo_cmp_host_user.dev", "instance_checksums.c0dea473-cfc0-49a7-830e-a7edc8f1125d"),
+				),
+			},
+		},
+	})
+}
+
+func testHostUserGetter() mapGetter {
+	return mapGetter{
+		"username":        "alice",
+		"uid":             1500,
+		"groups":          schema.NewSet(schema.HashString, []interface{}{"wheel", "docker"}),
+		"shell":           "/bin/bash",
+		"authorized_keys": []interface{}{"ssh-ed25519 AAAA alice@laptop"},
+		"sudo_rules":      []interface{}{"ALL=(ALL) NOPASSWD: ALL"},
+	}
+}
+
+func TestHostUserState(t *testing.T) {
+	d := testHostUserGetter()
+	want := fmt.Sprintf("uid=1500\nshell=/bin/bash\ngroups=docker,wheel\nauthorized_keys=%s\nsudo=%s\n",
+		sha256Hex([]byte("ssh-ed25519 AAAA alice@laptop\n")), sha256Hex([]byte("alice ALL=(ALL) NOPASSWD: ALL\n")))
+	if got := hostUserState(d); got != want {
+		t.Errorf("hostUserState() = %q, want %q", got, want)
+	}
+
+	d["uid"], d["authorized_keys"], d["sudo_rules"] = 0, []interface{}{}, []interface{}{}
+	if got := hostUserState(d); got != "uid=\nshell=/bin/bash\ngroups=docker,wheel\nauthorized_keys=\nsudo=\n" {
+		t.Errorf("unexpected state %q", got)
+	}
+}
+
+func TestHostUserApplyScript(t *testing.T) {
+	script := hostUserApplyScript(testHostUserGetter())
+	for _, want := range []string{
+		"getent group 'docker' >/dev/null || groupadd 'docker'\n",
+		"usermod -s '/bin/bash' -u 1500 -G 'docker,wheel' \"$__tf_bingo_user\"",
+		"useradd -m -s '/bin/bash' -u 1500 -G 'docker,wheel' \"$__tf_bingo_user\"",
+		"__tf_bingo_sudoers=/etc/sudoers.d/terraform-bingo-alice\n",
+		"visudo -cf \"$__tf_bingo_sudoers.tmp\"",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("apply script does not contain %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestHostUserObservedState(t *testing.T) {
+	result := &commandResult{Status: "success", Log: "__TF_BINGO__state=dWlkPQo=\n"}
+	if state, ok := hostUserObservedState(result); !ok || state != "uid=\n" {
+		t.Errorf("unexpected state %q, %v", state, ok)
+	}
+	result.Log = "__TF_BINGO__state=\n"
+	if state, ok := hostUserObservedState(result); !ok || state != "" {
+		t.Errorf("expected a missing user, got %q, %v", state, ok)
+	}
+}
+
+func testHostUserResourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_host_user" "dev" {
+  host_type       = "1"
+  instance_ids    = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  username        = "alice"
+  groups          = ["wheel"]
+  authorized_keys = ["ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIExample alice@example.com"]
+  sudo_rules      = ["ALL=(ALL) NOPASSWD: ALL"]
+}
+`)
+}
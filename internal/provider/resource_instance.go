@@ -0,0 +1,472 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+// vmStatusDeleted is what waitForVmStatus reports for a virtual machine which no longer exists.
+const vmStatusDeleted = "deleted"
+
+// vmPollInterval is how often the status of an instance is polled, tests shorten it.
+var vmPollInterval = 5 * time.Second
+
+func resourceCmpInstance() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "通过CMP创建和管理虚拟机，支持调整规格、开关机及删除。修改规格时将先关机，调整后按`power_state`恢复运行；" +
+			"修改镜像、网络、磁盘或用户数据将重建虚拟机",
+
+		CreateContext: resourceCmpInstanceCreate,
+		ReadContext:   resourceCmpInstanceRead,
+		UpdateContext: resourceCmpInstanceUpdate,
+		DeleteContext: resourceCmpInstanceDelete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  "虚拟机名称",
+			},
+			"project_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "项目编号，为空时使用默认项目",
+			},
+			"image_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "镜像编号",
+			},
+			"flavor_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "规格编号，修改时将关机调整规格",
+			},
+			"network_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "网络编号",
+			},
+			"subnet_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "子网编号",
+			},
+			"security_group_ids": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "安全组编号",
+			},
+			"system_disk_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "系统盘大小（GB），为空时使用镜像的大小",
+			},
+			"data_disk": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "数据盘",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"size": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.IntAtLeast(1),
+							Description:  "大小（GB）",
+						},
+						"type": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Computed:    true,
+							ForceNew:    true,
+							Description: "磁盘类型，为空时使用默认类型",
+						},
+						"delete_on_termination": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							ForceNew:    true,
+							Description: "是否随虚拟机一起删除",
+						},
+						"id": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "磁盘编号",
+						},
+					},
+				},
+			},
+			"tags": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "标签",
+			},
+			"user_data": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "用户数据，如cloud-init配置，仅在首次启动时执行",
+			},
+			"power_state": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      cmp.VmStatusRunning,
+				ValidateFunc: validation.StringInSlice([]string{cmp.VmStatusRunning, cmp.VmStatusStopped}, false),
+				Description:  "期望的电源状态，running:运行,stopped:关机",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "虚拟机状态",
+			},
+			"ip": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "IP地址",
+			},
+		},
+	}
+}
+
+func resourceCmpInstanceCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	input := &cmp.VmInput{
+		Name:             d.Get("name").(string),
+		ProjectId:        d.Get("project_id").(string),
+		ImageId:          d.Get("image_id").(string),
+		FlavorId:         d.Get("flavor_id").(string),
+		NetworkId:        d.Get("network_id").(string),
+		SubnetId:         d.Get("subnet_id").(string),
+		SecurityGroupIds: expandStringSet(d.Get("security_group_ids").(*schema.Set)),
+		SystemDiskSize:   d.Get("system_disk_size").(int),
+		DataDisks:        expandVmDisks(d.Get("data_disk").([]interface{})),
+		Tags:             expandInstanceTags(d.Get("tags").(map[string]interface{})),
+	}
+	if v := d.Get("user_data").(string); v != "" {
+		input.UserData = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+
+	output, err := client.cmpClient.CreateVm(ctx, input)
+	if err != nil {
+		return diagErrorf("[CMP] Unable to create instance %s, got error: %s", input.Name, err)
+	}
+
+	// Set the ID first, an instance which fails to start is tainted and deleted by the next apply.
+	d.SetId(output.Id)
+
+	timeout := d.Timeout(schema.TimeoutCreate)
+	if _, err := waitForVmStatus(ctx, client, d.Id(), []string{cmp.VmStatusCreating, cmp.VmStatusStarting}, []string{cmp.VmStatusRunning}, timeout); err != nil {
+		return diagErrorf("[CMP] Waiting for instance (%s) to be created: %s", d.Id(), err)
+	}
+	if d.Get("power_state").(string) == cmp.VmStatusStopped {
+		if err := stopVm(ctx, client, d.Id(), timeout); err != nil {
+			return diagErrorf("[CMP] Unable to stop instance (%s), got error: %s", d.Id(), err)
+		}
+	}
+
+	tflog.Debug(ctx, "[CMP] Created an instance successfully", map[string]interface{}{
+		"id":   d.Id(),
+		"name": input.Name,
+	})
+
+	return resourceCmpInstanceRead(ctx, d, meta)
+}
+
+func resourceCmpInstanceRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	output, err := client.cmpClient.DescribeVm(ctx, d.Id())
+	if err != nil {
+		return diagErrorf("[CMP] Unable to read instance (%s), got error: %s", d.Id(), err)
+	}
+	if output == nil {
+		tflog.Debug(ctx, "[CMP] Instance not found, removing it from the state", map[string]interface{}{"id": d.Id()})
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("name", output.Name)
+	d.Set("project_id", output.ProjectId)
+	d.Set("image_id", output.ImageId)
+	d.Set("flavor_id", output.FlavorId)
+	d.Set("network_id", output.NetworkId)
+	d.Set("subnet_id", output.SubnetId)
+	d.Set("security_group_ids", output.SecurityGroupIds)
+	d.Set("system_disk_size", output.SystemDiskSize)
+	d.Set("tags", flattenInstanceTags(output.Tags))
+	d.Set("status", output.Status)
+	d.Set("ip", output.Ip)
+	if err := d.Set("data_disk", flattenVmDisks(output.DataDisks)); err != nil {
+		return diagErrorf("[CMP] Unable to set data_disk: %s", err)
+	}
+	// Only a settled instance reports its power state, an instance in transition keeps the configured one.
+	if output.Status == cmp.VmStatusRunning || output.Status == cmp.VmStatusStopped {
+		d.Set("power_state", output.Status)
+	}
+
+	return nil
+}
+
+func resourceCmpInstanceUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	timeout := d.Timeout(schema.TimeoutUpdate)
+	if d.HasChanges("name", "security_group_ids", "tags") {
+		err := client.cmpClient.UpdateVm(ctx, &cmp.UpdateVmInput{
+			Id:               d.Id(),
+			Name:             d.Get("name").(string),
+			SecurityGroupIds: expandStringSet(d.Get("security_group_ids").(*schema.Set)),
+			Tags:             expandInstanceTags(d.Get("tags").(map[string]interface{})),
+		})
+		if err != nil {
+			return diagErrorf("[CMP] Unable to update instance (%s), got error: %s", d.Id(), err)
+		}
+	}
+
+	if d.HasChange("flavor_id") {
+		// A flavor can only be changed while the instance is stopped.
+		if err := stopVm(ctx, client, d.Id(), timeout); err != nil {
+			return diagErrorf("[CMP] Unable to stop instance (%s), got error: %s", d.Id(), err)
+		}
+		if err := client.cmpClient.ResizeVm(ctx, d.Id(), d.Get("flavor_id").(string)); err != nil {
+			return diagErrorf("[CMP] Unable to resize instance (%s), got error: %s", d.Id(), err)
+		}
+		if err := waitForVmResize(ctx, client, d.Id(), d.Get("flavor_id").(string), timeout); err != nil {
+			return diagErrorf("[CMP] Waiting for instance (%s) to be resized: %s", d.Id(), err)
+		}
+	}
+
+	var err error
+	if d.Get("power_state").(string) == cmp.VmStatusRunning {
+		err = startVm(ctx, client, d.Id(), timeout)
+	} else {
+		err = stopVm(ctx, client, d.Id(), timeout)
+	}
+	if err != nil {
+		return diagErrorf("[CMP] Unable to change the power state of instance (%s), got error: %s", d.Id(), err)
+	}
+
+	tflog.Debug(ctx, "[CMP] Updated an instance successfully", map[string]interface{}{"id": d.Id()})
+
+	return resourceCmpInstanceRead(ctx, d, meta)
+}
+
+func resourceCmpInstanceDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	if err := client.cmpClient.DeleteVm(ctx, d.Id()); err != nil {
+		// An instance which is already gone is what deleting it wants.
+		output, describeErr := client.cmpClient.DescribeVm(ctx, d.Id())
+		if describeErr != nil || output != nil {
+			return diagErrorf("[CMP] Unable to delete instance (%s), got error: %s", d.Id(), err)
+		}
+	}
+
+	// Instances in error are deleted too, so the error status is only one more step towards deleted.
+	stateConf := &resource.StateChangeConf{
+		Pending: []string{cmp.VmStatusCreating, cmp.VmStatusRunning, cmp.VmStatusStopped, cmp.VmStatusStopping,
+			cmp.VmStatusStarting, cmp.VmStatusResizing, cmp.VmStatusError, cmp.VmStatusDeleting},
+		Target:       []string{vmStatusDeleted},
+		Refresh:      refreshVmDeletion(ctx, client, d.Id()),
+		Timeout:      d.Timeout(schema.TimeoutDelete),
+		PollInterval: vmPollInterval,
+	}
+	if _, err := stateConf.WaitForStateContext(ctx); err != nil {
+		return diagErrorf("[CMP] Waiting for instance (%s) to be deleted: %s", d.Id(), err)
+	}
+
+	tflog.Debug(ctx, "[CMP] Deleted an instance successfully", map[string]interface{}{"id": d.Id()})
+
+	return nil
+}
+
+// startVm starts the instance unless it is running and waits for it to run.
+func startVm(ctx context.Context, client *bingoCloudClient, id string, timeout time.Duration) error {
+	output, err := waitForVmStatus(ctx, client, id, []string{cmp.VmStatusStarting, cmp.VmStatusStopping, cmp.VmStatusResizing},
+		[]string{cmp.VmStatusRunning, cmp.VmStatusStopped}, timeout)
+	if err != nil || output.Status == cmp.VmStatusRunning {
+		return err
+	}
+	if err := client.cmpClient.StartVm(ctx, id); err != nil {
+		return err
+	}
+	_, err = waitForVmStatus(ctx, client, id, []string{cmp.VmStatusStopped, cmp.VmStatusStarting}, []string{cmp.VmStatusRunning}, timeout)
+	return err
+}
+
+// stopVm stops the instance unless it is stopped and waits for it to stop.
+func stopVm(ctx context.Context, client *bingoCloudClient, id string, timeout time.Duration) error {
+	output, err := waitForVmStatus(ctx, client, id, []string{cmp.VmStatusStarting, cmp.VmStatusStopping, cmp.VmStatusResizing},
+		[]string{cmp.VmStatusRunning, cmp.VmStatusStopped}, timeout)
+	if err != nil || output.Status == cmp.VmStatusStopped {
+		return err
+	}
+	if err := client.cmpClient.StopVm(ctx, id); err != nil {
+		return err
+	}
+	_, err = waitForVmStatus(ctx, client, id, []string{cmp.VmStatusRunning, cmp.VmStatusStopping}, []string{cmp.VmStatusStopped}, timeout)
+	return err
+}
+
+// waitForVmStatus waits for the instance to reach one of target, an instance in error fails the wait.
+func waitForVmStatus(ctx context.Context, client *bingoCloudClient, id string, pending, target []string, timeout time.Duration) (*cmp.VmOutput, error) {
+	stateConf := &resource.StateChangeConf{
+		Pending:      pending,
+		Target:       target,
+		Refresh:      refreshVm(ctx, client, id),
+		Timeout:      timeout,
+		PollInterval: vmPollInterval,
+	}
+	v, err := stateConf.WaitForStateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	output, _ := v.(*cmp.VmOutput)
+	if output == nil {
+		output = &cmp.VmOutput{Id: id, Status: vmStatusDeleted}
+	}
+	return output, nil
+}
+
+func refreshVm(ctx context.Context, client *bingoCloudClient, id string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		output, err := client.cmpClient.DescribeVm(ctx, id)
+		if err != nil {
+			return nil, "", err
+		}
+		if output == nil {
+			// A non-nil result is needed for the deleted state to count as reached.
+			return &cmp.VmOutput{Id: id, Status: vmStatusDeleted}, vmStatusDeleted, nil
+		}
+		if output.Status == cmp.VmStatusError {
+			return output, output.Status, fmt.Errorf("instance is in error: %s", output.ErrorMessage)
+		}
+		return output, output.Status, nil
+	}
+}
+
+// waitForVmResize waits for the instance to be stopped with flavorId. The instance is stopped before the resize
+// starts as well, so the status alone does not tell whether it is over.
+func waitForVmResize(ctx context.Context, client *bingoCloudClient, id, flavorId string, timeout time.Duration) error {
+	refresh := refreshVm(ctx, client, id)
+	stateConf := &resource.StateChangeConf{
+		Pending: []string{cmp.VmStatusResizing},
+		Target:  []string{cmp.VmStatusStopped},
+		Refresh: func() (interface{}, string, error) {
+			v, status, err := refresh()
+			if err != nil || status == vmStatusDeleted {
+				return v, status, err
+			}
+			if status == cmp.VmStatusStopped && v.(*cmp.VmOutput).FlavorId != flavorId {
+				return v, cmp.VmStatusResizing, nil
+			}
+			return v, status, nil
+		},
+		Timeout:      timeout,
+		PollInterval: vmPollInterval,
+	}
+	_, err := stateConf.WaitForStateContext(ctx)
+	return err
+}
+
+// refreshVmDeletion is refreshVm without failing on the error status, which a deleted instance may pass through.
+func refreshVmDeletion(ctx context.Context, client *bingoCloudClient, id string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		output, err := client.cmpClient.DescribeVm(ctx, id)
+		if err != nil {
+			return nil, "", err
+		}
+		if output == nil {
+			return &cmp.VmOutput{Id: id, Status: vmStatusDeleted}, vmStatusDeleted, nil
+		}
+		return output, output.Status, nil
+	}
+}
+
+func expandStringSet(set *schema.Set) []string {
+	list := make([]string, 0, set.Len())
+	for _, v := range set.List() {
+		list = append(list, v.(string))
+	}
+	sort.Strings(list)
+	return list
+}
+
+func expandInstanceTags(m map[string]interface{}) []*cmp.InstanceTag {
+	tags := make([]*cmp.InstanceTag, 0, len(m))
+	for k, v := range m {
+		tags = append(tags, &cmp.InstanceTag{Key: k, Value: v.(string)})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	return tags
+}
+
+func flattenInstanceTags(tags []*cmp.InstanceTag) map[string]interface{} {
+	m := make(map[string]interface{}, len(tags))
+	for _, tag := range tags {
+		m[tag.Key] = tag.Value
+	}
+	return m
+}
+
+func expandVmDisks(list []interface{}) []*cmp.VmDisk {
+	disks := make([]*cmp.VmDisk, 0, len(list))
+	for _, item := range list {
+		m := item.(map[string]interface{})
+		disks = append(disks, &cmp.VmDisk{
+			Size:                m["size"].(int),
+			Type:                m["type"].(string),
+			DeleteOnTermination: m["delete_on_termination"].(bool),
+		})
+	}
+	return disks
+}
+
+func flattenVmDisks(disks []*cmp.VmDisk) []interface{} {
+	list := make([]interface{}, 0, len(disks))
+	for _, disk := range disks {
+		list = append(list, map[string]interface{}{
+			"id":                    disk.Id,
+			"size":                  disk.Size,
+			"type":                  disk.Type,
+			"delete_on_termination": disk.DeleteOnTermination,
+		})
+	}
+	return list
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+func TestInstanceResource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testInstanceResourceConfig("c2m4"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_instance.dev", "status", "running"),
+					resource.TestCheckResourceAttrSet("bingo_cmp_instance.dev", "ip"),
+				),
+			},
+			{
+				Config: testInstanceResourceConfig("c4m8"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_instance.dev", "flavor_id", "c4m8"),
+					resource.TestCheckResourceAttr("bingo_cmp_instance.dev", "power_state", "running"),
+				),
+			},
+		},
+	})
+}
+
+func TestInstanceTags(t *testing.T) {
+	tags := expandInstanceTags(map[string]interface{}{"team": "ops", "env": "dev"})
+	want := []*cmp.InstanceTag{{Key: "env", Value: "dev"}, {Key: "team", Value: "ops"}}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("expandInstanceTags() = %v, want %v", tags, want)
+	}
+	if m := flattenInstanceTags(tags); m["env"] != "dev" || m["team"] != "ops" {
+		t.Errorf("unexpected tags %v", m)
+	}
+}
+
+func TestRefreshVm(t *testing.T) {
+	body := `{"id":"vm-1","status":"error","errorMessage":"no capacity"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := &bingoCloudClient{cmpClient: cmp.New(server.URL, "")}
+	refresh := refreshVm(context.Background(), client, "vm-1")
+	if _, status, err := refresh(); status != cmp.VmStatusError || err == nil {
+		t.Errorf("expected an error, got %s, %v", status, err)
+	}
+	if _, status, err := refreshVmDeletion(context.Background(), client, "vm-1")(); status != cmp.VmStatusError || err != nil {
+		t.Errorf("expected the deletion to go on, got %s, %v", status, err)
+	}
+
+	body = ""
+	v, status, err := refresh()
+	if err != nil || status != vmStatusDeleted || v == nil {
+		t.Errorf("expected a deleted instance, got %v, %s, %v", v, status, err)
+	}
+}
+
+func TestWaitForVmResize(t *testing.T) {
+	defer func(interval time.Duration) { vmPollInterval = interval }(vmPollInterval)
+	vmPollInterval = 10 * time.Millisecond
+
+	// The instance is still stopped with its old flavor until the resize starts.
+	bodies := []string{
+		`{"id":"vm-1","status":"stopped","flavorId":"small"}`,
+		`{"id":"vm-1","status":"resizing","flavorId":"large"}`,
+		`{"id":"vm-1","status":"stopped","flavorId":"large"}`,
+	}
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1)) - 1
+		if n >= len(bodies) {
+			n = len(bodies) - 1
+		}
+		_, _ = w.Write([]byte(bodies[n]))
+	}))
+	defer server.Close()
+
+	client := &bingoCloudClient{cmpClient: cmp.New(server.URL, "")}
+	if err := waitForVmResize(context.Background(), client, "vm-1", "large", time.Minute); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != int32(len(bodies)) {
+		t.Fatalf("expected to wait for the resized instance, got %d requests", n)
+	}
+}
+
+func testInstanceResourceConfig(flavorId string) string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_instance" "dev" {
+  name               = "tf-dev"
+  image_id           = "img-centos7"
+  flavor_id          = %q
+  network_id         = "net-1"
+  subnet_id          = "subnet-1"
+  security_group_ids = ["sg-1"]
+
+  data_disk {
+    size = 100
+  }
+
+  tags = {
+    env = "dev"
+  }
+
+  user_data = <<-EOT
+    #cloud-config
+    packages: [nginx]
+  EOT
+}
+`, flavorId)
+}
@@ -0,0 +1,276 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+const (
+	jobModeOrdered = "ordered"
+	jobModeDAG     = "dag"
+)
+
+func resourceCmpJob() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "由多个步骤组成的CMP作业，按顺序或依赖关系执行各步骤，步骤失败时停止、继续或回滚。修改任何参数将重新执行作业",
+
+		CreateContext: resourceCmpJobCreate,
+		ReadContext:   resourceCmpJobRead,
+		DeleteContext: resourceCmpJobDelete,
+
+		CustomizeDiff: resourceCmpJobCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"host_type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.HostTypeVirtualMachine, cmp.HostTypePhysical}, false),
+				Description:  "宿主机类型，1:虚拟机,2:物理机",
+			},
+			"instance_ids": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "步骤默认的实例编号，多个用逗号分割",
+			},
+			"mode": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      jobModeOrdered,
+				ValidateFunc: validation.StringInSlice([]string{jobModeOrdered, jobModeDAG}, false),
+				Description:  "执行方式，ordered:未设置`depends_on`的步骤依赖上一步骤,dag:仅按`depends_on`执行，没有依赖的步骤并发执行",
+			},
+			"step": {
+				Type:        schema.TypeList,
+				Required:    true,
+				ForceNew:    true,
+				MinItems:    1,
+				Description: "作业步骤",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "步骤名称，在作业内唯一",
+						},
+						"content": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "命令内容",
+						},
+						"rollback_content": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "回滚命令内容，作业回滚时执行",
+						},
+						"instance_ids": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "实例编号，多个用逗号分割，为空时使用作业的`instance_ids`",
+						},
+						"depends_on": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "依赖的步骤名称，依赖的步骤均结束后才执行",
+						},
+						"timeout": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "10m",
+							ValidateFunc: validateDuration,
+							Description:  "单次执行的超时时间，如`10m`",
+						},
+						"retries": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      0,
+							ValidateFunc: validation.IntBetween(0, 10),
+							Description:  "失败后的重试次数",
+						},
+						"retry_interval": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "10s",
+							ValidateFunc: validateDuration,
+							Description:  "重试间隔，如`10s`",
+						},
+						"on_failure": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      jobOnFailureStop,
+							ValidateFunc: validation.StringInSlice([]string{jobOnFailureStop, jobOnFailureContinue, jobOnFailureRollback}, false),
+							Description:  "失败时的处理方式，stop:停止作业,continue:继续执行后续步骤,rollback:停止作业并回滚已执行的步骤",
+						},
+					},
+				},
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "作业状态，success:成功,failed:失败",
+			},
+			"results": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "各步骤的执行结果，与`step`顺序一致",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name":      {Type: schema.TypeString, Computed: true, Description: "步骤名称"},
+						"status":    {Type: schema.TypeString, Computed: true, Description: "步骤状态，success:成功,failed:失败,skipped:未执行,rolled_back:已回滚"},
+						"record_id": {Type: schema.TypeString, Computed: true, Description: "最后一次执行的记录ID"},
+						"task_id":   {Type: schema.TypeString, Computed: true, Description: "最后一次执行的任务ID"},
+						"attempts":  {Type: schema.TypeInt, Computed: true, Description: "执行次数"},
+						"message":   {Type: schema.TypeString, Computed: true, Description: "失败或未执行的原因"},
+						"instance_status": {
+							Type:        schema.TypeMap,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "最后一次执行时各实例的状态",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func validateDuration(v interface{}, k string) (ws []string, errs []error) {
+	if _, err := time.ParseDuration(v.(string)); err != nil {
+		errs = append(errs, fmt.Errorf("%q: %s", k, err))
+	}
+	return
+}
+
+func resourceCmpJobCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	// Steps built from unknown values can not be checked yet, they are checked again on apply.
+	if !d.NewValueKnown("step") || !d.NewValueKnown("instance_ids") {
+		return nil
+	}
+	_, err := expandJobSteps(d)
+	return err
+}
+
+func resourceCmpJobCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	steps, err := expandJobSteps(d)
+	if err != nil {
+		return diagErrorf("[CMP] Invalid job: %s", err)
+	}
+
+	hostType := d.Get("host_type").(string)
+	results, err := runJob(ctx, steps, func(ctx context.Context, step *jobStep, content string) (map[string]*commandResult, error) {
+		return runCommand(ctx, client, &commandRun{
+			HostType:    hostType,
+			InstanceIds: step.InstanceIds,
+			Name:        "terraform-job-" + step.Name,
+			Content:     content,
+			Timeout:     step.Timeout,
+		})
+	})
+
+	// A failed job is kept as tainted with its results, so the next apply runs it again.
+	d.SetId(resource.UniqueId())
+	d.Set("status", jobStepStatusSuccess)
+	if err != nil {
+		d.Set("status", jobStepStatusFailed)
+	}
+	if setErr := d.Set("results", flattenJobResults(results)); setErr != nil {
+		return diagErrorf("[CMP] Unable to set results: %s", setErr)
+	}
+	if err != nil {
+		return diagErrorf("[CMP] Job failed: %s", err)
+	}
+
+	tflog.Debug(ctx, "[CMP] Ran a job successfully", map[string]interface{}{
+		"id":    d.Id(),
+		"steps": len(steps),
+	})
+
+	return nil
+}
+
+func resourceCmpJobRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// A job runs once, its results are those recorded on create.
+	return nil
+}
+
+func resourceCmpJobDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	tflog.Debug(ctx, "[CMP] Deleted a job successfully", map[string]interface{}{"id": d.Id()})
+	return nil
+}
+
+// expandJobSteps reads the `step` blocks, applying the job defaults and the ordered mode.
+func expandJobSteps(d resourceGetter) ([]*jobStep, error) {
+	defaultIds := splitInstanceIds(d.Get("instance_ids").(string))
+	ordered := d.Get("mode").(string) == jobModeOrdered
+
+	list := d.Get("step").([]interface{})
+	steps := make([]*jobStep, 0, len(list))
+	for i, item := range list {
+		m := item.(map[string]interface{})
+		step := &jobStep{
+			Name:            m["name"].(string),
+			Content:         m["content"].(string),
+			RollbackContent: m["rollback_content"].(string),
+			InstanceIds:     splitInstanceIds(m["instance_ids"].(string)),
+			Retries:         m["retries"].(int),
+			OnFailure:       m["on_failure"].(string),
+		}
+		if len(step.InstanceIds) == 0 {
+			step.InstanceIds = defaultIds
+		}
+		if len(step.InstanceIds) == 0 {
+			return nil, fmt.Errorf("step %q has no instance_ids and the job has no default", step.Name)
+		}
+		for _, dep := range m["depends_on"].([]interface{}) {
+			step.DependsOn = append(step.DependsOn, dep.(string))
+		}
+		if ordered && len(step.DependsOn) == 0 && i > 0 {
+			step.DependsOn = []string{steps[i-1].Name}
+		}
+		var err error
+		if step.Timeout, err = time.ParseDuration(m["timeout"].(string)); err != nil {
+			return nil, fmt.Errorf("step %q: %s", step.Name, err)
+		}
+		if step.RetryInterval, err = time.ParseDuration(m["retry_interval"].(string)); err != nil {
+			return nil, fmt.Errorf("step %q: %s", step.Name, err)
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, validateJobSteps(steps)
+}
+
+func flattenJobResults(results []*jobStepResult) []interface{} {
+	list := make([]interface{}, 0, len(results))
+	for _, result := range results {
+		list = append(list, map[string]interface{}{
+			"name":            result.Name,
+			"status":          result.Status,
+			"record_id":       result.RecordId,
+			"task_id":         result.TaskId,
+			"attempts":        result.Attempts,
+			"message":         result.Message,
+			"instance_status": result.InstanceStatus,
+		})
+	}
+	return list
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestJobResource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testJobResourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_job.dev", "status", "success"),
+					resource.TestCheckResourceAttr("bingo_cmp_job.dev", "results.#", "2"),
+					resource.TestCheckResourceAttr("bingo_cmp_job.dev", "results.1.status", "success"),
+				),
+			},
+		},
+	})
+}
+
+func testJobResourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_job" "dev" {
+  host_type    = "1"
+  instance_ids = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+
+  step {
+    name             = "backup"
+    content          = "cp /etc/hosts /tmp/hosts.bak"
+    rollback_content = "rm -f /tmp/hosts.bak"
+  }
+
+  step {
+    name       = "check"
+    content    = "test -f /tmp/hosts.bak"
+    retries    = 2
+    on_failure = "rollback"
+  }
+}
+`)
+}
@@ -0,0 +1,290 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+func resourceCmpManagedState() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "通过检查脚本和应用脚本管理实例上的配置（仅支持Linux）。" +
+			"读取时在各实例上执行检查脚本并与期望输出比较，计划中按实例展示漂移，应用时仅在漂移的实例上执行应用脚本",
+
+		CreateContext: resourceCmpManagedStateCreate,
+		ReadContext:   resourceCmpManagedStateRead,
+		UpdateContext: resourceCmpManagedStateUpdate,
+		DeleteContext: resourceCmpManagedStateDelete,
+
+		CustomizeDiff: resourceCmpManagedStateCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Read:   schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"host_type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.HostTypeVirtualMachine, cmp.HostTypePhysical}, false),
+				Description:  "宿主机类型，1:虚拟机,2:物理机",
+			},
+			"instance_ids": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "实例编号，多个用逗号分割",
+			},
+			"check_content": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "检查脚本，其标准输出（去除首尾空白）与`expected_output`比较，退出码非0时视为不一致，输出记为`<exit 退出码>`",
+			},
+			"expected_output": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "检查脚本的期望输出",
+			},
+			"apply_content": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "应用脚本，在检查结果与期望不一致的实例上执行",
+			},
+			"destroy_content": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "删除脚本，删除资源或移除实例时执行，为空时仅从状态中移除",
+			},
+			"instance_outputs": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "各实例上检查脚本的输出",
+			},
+		},
+	}
+}
+
+func resourceCmpManagedStateCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("instance_ids") || !d.NewValueKnown("expected_output") {
+		return d.SetNewComputed("instance_outputs")
+	}
+
+	// The desired state is the expected output on every instance, a differing observed output shows up as drift.
+	expected := map[string]interface{}{}
+	for _, id := range splitInstanceIds(d.Get("instance_ids").(string)) {
+		expected[id] = strings.TrimSpace(d.Get("expected_output").(string))
+	}
+	return d.SetNew("instance_outputs", expected)
+}
+
+func resourceCmpManagedStateCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	// Check first and only apply to the instances which do not pass.
+	instanceIds := splitInstanceIds(d.Get("instance_ids").(string))
+	checked, err := checkManagedState(ctx, client, d, instanceIds, d.Timeout(schema.TimeoutCreate))
+	if err != nil {
+		return diagErrorf("[CMP] Unable to check managed state, got error: %s", err)
+	}
+	expected := strings.TrimSpace(d.Get("expected_output").(string))
+	outputs := map[string]interface{}{}
+	var targets []string
+	for _, id := range instanceIds {
+		if output, ok := checked[id]; ok && output == expected {
+			outputs[id] = output
+			continue
+		}
+		targets = append(targets, id)
+	}
+	if len(targets) > 0 {
+		converged, err := convergeManagedState(ctx, client, d, targets, d.Timeout(schema.TimeoutCreate))
+		if err != nil {
+			return diagErrorf("[CMP] Unable to apply managed state, got error: %s", err)
+		}
+		for id, v := range converged {
+			outputs[id] = v
+		}
+	}
+
+	d.SetId(resource.UniqueId())
+	d.Set("instance_outputs", outputs)
+
+	tflog.Debug(ctx, "[CMP] Created a managed state successfully", map[string]interface{}{
+		"id":      d.Id(),
+		"targets": targets,
+	})
+
+	return nil
+}
+
+func resourceCmpManagedStateRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	instanceIds := splitInstanceIds(d.Get("instance_ids").(string))
+	results, err := runCommand(ctx, client, &commandRun{
+		HostType:    d.Get("host_type").(string),
+		InstanceIds: instanceIds,
+		Name:        "terraform-state-check",
+		Content:     managedStateCheckScript(d.Get("check_content").(string)),
+		Timeout:     d.Timeout(schema.TimeoutRead),
+	})
+	if err != nil {
+		return diagErrorf("[CMP] Unable to check managed state, got error: %s", err)
+	}
+
+	return observeInstances(d, results, []string{"instance_outputs"}, "managed state",
+		func(result *commandResult) (map[string]string, bool) {
+			output, ok := managedStateOutput(result)
+			return map[string]string{"instance_outputs": output}, ok
+		})
+}
+
+func resourceCmpManagedStateUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	// Only converge the drifted instances, unless the scripts or the expectation changed.
+	instanceIds, removed, targets := updateTargets(d, []string{"check_content", "expected_output", "apply_content"},
+		priorDrift(d, "instance_outputs", strings.TrimSpace(d.Get("expected_output").(string))))
+	if len(removed) > 0 {
+		if err := destroyManagedState(ctx, client, d, removed, d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return diagErrorf("[CMP] Unable to destroy managed state, got error: %s", err)
+		}
+	}
+
+	outputs := map[string]interface{}{}
+	for _, id := range instanceIds {
+		outputs[id] = strings.TrimSpace(d.Get("expected_output").(string))
+	}
+	if len(targets) > 0 {
+		converged, err := convergeManagedState(ctx, client, d, targets, d.Timeout(schema.TimeoutUpdate))
+		if err != nil {
+			return diagErrorf("[CMP] Unable to apply managed state, got error: %s", err)
+		}
+		for id, v := range converged {
+			outputs[id] = v
+		}
+	}
+
+	d.Set("instance_outputs", outputs)
+
+	tflog.Debug(ctx, "[CMP] Updated a managed state successfully", map[string]interface{}{
+		"id":      d.Id(),
+		"targets": targets,
+	})
+
+	return nil
+}
+
+func resourceCmpManagedStateDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	if err := destroyManagedState(ctx, client, d, splitInstanceIds(d.Get("instance_ids").(string)), d.Timeout(schema.TimeoutDelete)); err != nil {
+		return diagErrorf("[CMP] Unable to destroy managed state, got error: %s", err)
+	}
+	return nil
+}
+
+// convergeManagedState runs the apply script on the instances, then checks them again and fails
+// unless every instance reports the expected output.
+func convergeManagedState(ctx context.Context, client *bingoCloudClient, d *schema.ResourceData, instanceIds []string, timeout time.Duration) (map[string]interface{}, error) {
+	if err := runOnAll(ctx, client, d.Get("host_type").(string), instanceIds, "terraform-state-apply", d.Get("apply_content").(string), timeout); err != nil {
+		return nil, err
+	}
+
+	checked, err := checkManagedState(ctx, client, d, instanceIds, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := strings.TrimSpace(d.Get("expected_output").(string))
+	outputs := map[string]interface{}{}
+	var unconverged []string
+	for _, id := range instanceIds {
+		output, ok := checked[id]
+		if !ok || output != expected {
+			unconverged = append(unconverged, fmt.Sprintf("%s: %q", id, output))
+			continue
+		}
+		outputs[id] = output
+	}
+	if len(unconverged) > 0 {
+		sort.Strings(unconverged)
+		return nil, fmt.Errorf("check output differs from %q after apply on %d instance(s):\n%s",
+			expected, len(unconverged), strings.Join(unconverged, "\n"))
+	}
+	return outputs, nil
+}
+
+// checkManagedState runs the check script and returns the output of every instance which could be checked.
+func checkManagedState(ctx context.Context, client *bingoCloudClient, d *schema.ResourceData, instanceIds []string, timeout time.Duration) (map[string]string, error) {
+	results, err := runCommand(ctx, client, &commandRun{
+		HostType:    d.Get("host_type").(string),
+		InstanceIds: instanceIds,
+		Name:        "terraform-state-check",
+		Content:     managedStateCheckScript(d.Get("check_content").(string)),
+		Timeout:     timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := map[string]string{}
+	for id, result := range results {
+		if output, ok := managedStateOutput(result); ok {
+			outputs[id] = output
+		}
+	}
+	return outputs, nil
+}
+
+func destroyManagedState(ctx context.Context, client *bingoCloudClient, d *schema.ResourceData, instanceIds []string, timeout time.Duration) error {
+	script := d.Get("destroy_content").(string)
+	if script == "" {
+		return nil
+	}
+	return runOnAll(ctx, client, d.Get("host_type").(string), instanceIds, "terraform-state-destroy", script, timeout)
+}
+
+// managedStateCheckScript runs the check script and prints its output base64 encoded as the `output` marker,
+// so multiline output survives the step log, and its exit code as the `rc` marker.
+func managedStateCheckScript(check string) string {
+	return fmt.Sprintf("__tf_bingo_output=$(\n%s\n)\n__tf_bingo_rc=$?\n%s\n%s\n", check,
+		scriptMarker("output", `$(printf '%s' "$__tf_bingo_output" | base64 | tr -d '\n')`),
+		scriptMarker("rc", "$__tf_bingo_rc"))
+}
+
+// managedStateOutput returns the trimmed output of the check script, see managedStateCheckScript.
+// A check exiting non-zero did not pass whatever it printed, its output is then `<exit N>`.
+func managedStateOutput(result *commandResult) (string, bool) {
+	if !result.Succeeded() {
+		return "", false
+	}
+	encoded, ok := result.Marker("output")
+	if !ok {
+		return "", false
+	}
+	output, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	if rc, _ := result.Marker("rc"); rc != "0" {
+		return fmt.Sprintf("<exit %s>", rc), true
+	}
+	return strings.TrimSpace(string(output)), true
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestManagedStateResource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testManagedStateResourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_managed_state.dev", "instance_outputs.c0dea473-cfc0-49a7-830e-a7edc8f1125d", "Asia/Shanghai"),
+				),
+			},
+		},
+	})
+}
+
+func TestManagedStateCheckScript(t *testing.T) {
+	check := "echo '  first line'\necho \"second 'line'\""
+	output, err := exec.Command("sh", "-c", managedStateCheckScript(check)).CombinedOutput()
+	if err != nil {
+		t.Fatalf("script failed: %s\n%s", err, output)
+	}
+
+	result := &commandResult{Status: "success", Log: "agent header\n" + string(output)}
+	got, ok := managedStateOutput(result)
+	if !ok || got != "first line\nsecond 'line'" {
+		t.Fatalf("unexpected output %q in %s", got, output)
+	}
+
+	// A failing check does not pass even when it prints the expected output.
+	output, err = exec.Command("sh", "-c", managedStateCheckScript(check+"\nexit 3")).CombinedOutput()
+	if err != nil {
+		t.Fatalf("script failed: %s\n%s", err, output)
+	}
+	if got, ok := managedStateOutput(&commandResult{Status: "success", Log: string(output)}); !ok || got != "<exit 3>" {
+		t.Fatalf("unexpected output %q in %s", got, output)
+	}
+
+	result.Status = "failed"
+	if _, ok := managedStateOutput(result); ok {
+		t.Fatalf("expected no output for a failed step")
+	}
+}
+
+func testManagedStateResourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_managed_state" "dev" {
+  host_type       = "1"
+  instance_ids    = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  check_content   = "timedatectl show -p Timezone --value"
+  expected_output = "Asia/Shanghai"
+  apply_content   = "timedatectl set-timezone Asia/Shanghai"
+}
+`)
+}
@@ -0,0 +1,367 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+const (
+	packageStatePresent = "present"
+	packageStateAbsent  = "absent"
+	packageStateLatest  = "latest"
+)
+
+const packageManagerAuto = "auto"
+
+var (
+	packageManagers      = []string{packageManagerAuto, "yum", "dnf", "apt", "zypper"}
+	linuxPackageRegexp   = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+	packageVersionRegexp = regexp.MustCompile(`^[A-Za-z0-9_.+:~-]+$`)
+	packageEpochRegexp   = regexp.MustCompile(`^[0-9]+:`)
+)
+
+func resourceCmpPackage() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "通过CMP指令管理实例上的软件包（仅支持Linux），读取时查询各实例已安装的版本以发现漂移，删除时卸载软件包",
+
+		CreateContext: resourceCmpPackageCreate,
+		ReadContext:   resourceCmpPackageRead,
+		UpdateContext: resourceCmpPackageUpdate,
+		DeleteContext: resourceCmpPackageDelete,
+
+		CustomizeDiff: resourceCmpPackageCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Read:   schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"host_type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.HostTypeVirtualMachine, cmp.HostTypePhysical}, false),
+				Description:  "宿主机类型，1:虚拟机,2:物理机",
+			},
+			"instance_ids": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "实例编号，多个用逗号分割",
+			},
+			"name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringMatch(linuxPackageRegexp, "must be a package name"),
+				Description:  "软件包名称",
+			},
+			"version": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringMatch(packageVersionRegexp, "must be a package version"),
+				Description:  "软件包版本，如`1.20.1`或`1.20.1-1.el7`，为空时安装任意版本，仅`state`为`present`时生效",
+			},
+			"state": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      packageStatePresent,
+				ValidateFunc: validation.StringInSlice([]string{packageStatePresent, packageStateAbsent, packageStateLatest}, false),
+				Description:  "期望状态，present:已安装,absent:未安装,latest:已安装最新版本",
+			},
+			"package_manager": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      packageManagerAuto,
+				ValidateFunc: validation.StringInSlice(packageManagers, false),
+				Description:  "包管理器，可选值：" + strings.Join(packageManagers, ",") + "，auto表示自动识别",
+			},
+			"instance_versions": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "各实例已安装的版本，未安装时为空",
+			},
+			"latest_versions": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "各实例可安装的最新版本，仅`state`为`latest`时查询",
+			},
+		},
+	}
+}
+
+func resourceCmpPackageCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("instance_ids") || !d.NewValueKnown("state") || !d.NewValueKnown("version") {
+		return d.SetNewComputed("instance_versions")
+	}
+
+	// Instances which already satisfy the desired state keep their version, the version the others
+	// converge to is only known once the package manager has run.
+	observed := d.Get("instance_versions").(map[string]interface{})
+	latest := d.Get("latest_versions").(map[string]interface{})
+	expected := map[string]interface{}{}
+	for _, id := range splitInstanceIds(d.Get("instance_ids").(string)) {
+		installed, _ := observed[id].(string)
+		available, _ := latest[id].(string)
+		if !packageSatisfied(d.Get("state").(string), d.Get("version").(string), installed, available) {
+			return d.SetNewComputed("instance_versions")
+		}
+		expected[id] = installed
+	}
+	return d.SetNew("instance_versions", expected)
+}
+
+func resourceCmpPackageCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	versions, err := applyPackage(ctx, client, d, splitInstanceIds(d.Get("instance_ids").(string)), d.Timeout(schema.TimeoutCreate))
+	if err != nil {
+		return diagErrorf("[CMP] Unable to apply package %s, got error: %s", d.Get("name"), err)
+	}
+
+	d.SetId(fmt.Sprintf("%s:%s", d.Get("host_type"), d.Get("name")))
+	d.Set("instance_versions", versions)
+	d.Set("latest_versions", map[string]interface{}{})
+
+	return nil
+}
+
+func resourceCmpPackageRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	results, err := runCommand(ctx, client, &commandRun{
+		HostType:    d.Get("host_type").(string),
+		InstanceIds: splitInstanceIds(d.Get("instance_ids").(string)),
+		Name:        "terraform-package-read",
+		Content:     packageReadScript(d),
+		Timeout:     d.Timeout(schema.TimeoutRead),
+	})
+	if err != nil {
+		return diagErrorf("[CMP] Unable to read package %s, got error: %s", d.Get("name"), err)
+	}
+
+	return observeInstances(d, results, []string{"instance_versions", "latest_versions"}, fmt.Sprintf("package %s", d.Get("name")),
+		func(result *commandResult) (map[string]string, bool) {
+			version, ok := result.Marker("version")
+			if !result.Succeeded() || !ok {
+				return nil, false
+			}
+			values := map[string]string{"instance_versions": version}
+			if v, ok := result.Marker("latest"); ok && v != "" {
+				values["latest_versions"] = v
+			}
+			return values, true
+		})
+}
+
+func resourceCmpPackageUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	// Only converge the instances which do not satisfy the desired state, unless it changed.
+	observed, _ := d.GetChange("instance_versions")
+	instanceIds, removed, targets := updateTargets(d, []string{"version", "state"}, func(instanceIds []string) []string {
+		return driftedPackages(d, instanceIds, observed.(map[string]interface{}))
+	})
+	if len(removed) > 0 {
+		if err := removePackage(ctx, client, d, removed, d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return diagErrorf("[CMP] Unable to remove package %s, got error: %s", d.Get("name"), err)
+		}
+	}
+
+	versions := map[string]interface{}{}
+	for _, id := range instanceIds {
+		copyMapValue(versions, observed.(map[string]interface{}), id)
+	}
+	if len(targets) > 0 {
+		applied, err := applyPackage(ctx, client, d, targets, d.Timeout(schema.TimeoutUpdate))
+		if err != nil {
+			return diagErrorf("[CMP] Unable to apply package %s, got error: %s", d.Get("name"), err)
+		}
+		for id, v := range applied {
+			versions[id] = v
+		}
+	}
+	d.Set("instance_versions", versions)
+
+	tflog.Debug(ctx, "[CMP] Updated a package successfully", map[string]interface{}{
+		"name":    d.Get("name"),
+		"targets": targets,
+	})
+
+	return nil
+}
+
+func resourceCmpPackageDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	if d.Get("state").(string) == packageStateAbsent {
+		return nil
+	}
+	if err := removePackage(ctx, client, d, splitInstanceIds(d.Get("instance_ids").(string)), d.Timeout(schema.TimeoutDelete)); err != nil {
+		return diagErrorf("[CMP] Unable to remove package %s, got error: %s", d.Get("name"), err)
+	}
+	return nil
+}
+
+// packageSatisfied reports whether the installed version satisfies the desired state, an instance whose
+// latest version is not known yet is satisfied by any installed version.
+func packageSatisfied(state, version, installed, latest string) bool {
+	switch state {
+	case packageStateAbsent:
+		return installed == ""
+	case packageStateLatest:
+		return installed != "" && (latest == "" || packageVersionMatches(installed, latest))
+	default:
+		return installed != "" && (version == "" || packageVersionMatches(installed, version))
+	}
+}
+
+// packageVersionMatches reports whether the installed version is want, ignoring the epoch and,
+// when want has no release, the release.
+func packageVersionMatches(installed, want string) bool {
+	if installed == "" {
+		return false
+	}
+	installed, want = packageEpochRegexp.ReplaceAllString(installed, ""), packageEpochRegexp.ReplaceAllString(want, "")
+	return installed == want || strings.HasPrefix(installed, want+"-")
+}
+
+func driftedPackages(d *schema.ResourceData, instanceIds []string, observed map[string]interface{}) []string {
+	latest := d.Get("latest_versions").(map[string]interface{})
+	var drifted []string
+	for _, id := range instanceIds {
+		installed, ok := observed[id].(string)
+		available, _ := latest[id].(string)
+		if !ok || !packageSatisfied(d.Get("state").(string), d.Get("version").(string), installed, available) {
+			drifted = append(drifted, id)
+		}
+	}
+	return drifted
+}
+
+// applyPackage converges the instances and returns the version each one reports afterwards.
+func applyPackage(ctx context.Context, client *bingoCloudClient, d *schema.ResourceData, instanceIds []string, timeout time.Duration) (map[string]interface{}, error) {
+	results, err := runCommand(ctx, client, &commandRun{
+		HostType:    d.Get("host_type").(string),
+		InstanceIds: instanceIds,
+		Name:        "terraform-package-apply",
+		Content:     packageApplyScript(d),
+		Timeout:     timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := failedResults(results); err != nil {
+		return nil, err
+	}
+
+	versions := map[string]interface{}{}
+	for id, result := range results {
+		versions[id], _ = result.Marker("version")
+	}
+	return versions, nil
+}
+
+func removePackage(ctx context.Context, client *bingoCloudClient, d *schema.ResourceData, instanceIds []string, timeout time.Duration) error {
+	return runOnAll(ctx, client, d.Get("host_type").(string), instanceIds, "terraform-package-remove",
+		packagePrelude(d)+packageActionScript(packageStateAbsent, d.Get("name").(string), ""), timeout)
+}
+
+// packagePrelude selects the package manager and defines the function printing the installed version.
+func packagePrelude(d resourceGetter) string {
+	name := shellQuote(d.Get("name").(string))
+
+	var b strings.Builder
+	if pm := d.Get("package_manager").(string); pm != packageManagerAuto {
+		fmt.Fprintf(&b, "__tf_bingo_pm=%s\n", pm)
+	} else {
+		b.WriteString("for __tf_bingo_pm in dnf yum apt-get zypper none; do command -v $__tf_bingo_pm >/dev/null 2>&1 && break; done\n")
+		b.WriteString("[ \"$__tf_bingo_pm\" = apt-get ] && __tf_bingo_pm=apt\n")
+		b.WriteString("[ \"$__tf_bingo_pm\" = none ] && { echo 'no supported package manager found' >&2; exit 1; }\n")
+	}
+	b.WriteString("__tf_bingo_installed() {\n")
+	b.WriteString("  case \"$__tf_bingo_pm\" in\n")
+	fmt.Fprintf(&b, "  apt) dpkg-query -W -f='${Status} ${Version}\\n' %s 2>/dev/null | awk '$3 == \"installed\" {print $4}' ;;\n", name)
+	fmt.Fprintf(&b, "  *) rpm -q --qf '%%{VERSION}-%%{RELEASE}\\n' %s 2>/dev/null | grep -v 'not installed' | tail -n 1 ;;\n", name)
+	b.WriteString("  esac\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func packageReadScript(d resourceGetter) string {
+	var b strings.Builder
+	b.WriteString(packagePrelude(d))
+	b.WriteString(scriptMarker("version", "$(__tf_bingo_installed)"))
+	b.WriteString("\n")
+	if d.Get("state").(string) == packageStateLatest {
+		name := shellQuote(d.Get("name").(string))
+		b.WriteString("case \"$__tf_bingo_pm\" in\n")
+		fmt.Fprintf(&b, "apt) __tf_bingo_latest=$(apt-cache policy %s 2>/dev/null | awk '/Candidate:/ {print $2}') ;;\n", name)
+		fmt.Fprintf(&b, "zypper) __tf_bingo_latest=$(zypper -q -n info %s 2>/dev/null | awk -F': *' '/^Version/ {print $2}') ;;\n", name)
+		fmt.Fprintf(&b, "*) __tf_bingo_latest=$($__tf_bingo_pm -q list %s 2>/dev/null | awk 'NF == 3 {v = $2} END {print v}') ;;\n", name)
+		b.WriteString("esac\n")
+		b.WriteString("[ \"$__tf_bingo_latest\" = '(none)' ] && __tf_bingo_latest=\n")
+		b.WriteString(scriptMarker("latest", "$__tf_bingo_latest"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func packageApplyScript(d resourceGetter) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	b.WriteString(packagePrelude(d))
+	state, version := d.Get("state").(string), ""
+	if state == packageStatePresent {
+		version = d.Get("version").(string)
+	}
+	b.WriteString(packageActionScript(state, d.Get("name").(string), version))
+	b.WriteString(scriptMarker("version", "$(__tf_bingo_installed)"))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// packageActionScript installs, upgrades or removes the package with the selected package manager.
+func packageActionScript(state, name, version string) string {
+	pkg, aptPkg := shellQuote(name), shellQuote(name)
+	if version != "" {
+		pkg, aptPkg = shellQuote(name+"-"+version), shellQuote(name+"="+version)
+	}
+
+	var yum, apt, zypper string
+	switch state {
+	case packageStateAbsent:
+		yum = fmt.Sprintf("if [ -n \"$(__tf_bingo_installed)\" ]; then $__tf_bingo_pm remove -y %s; fi", pkg)
+		apt = fmt.Sprintf("if [ -n \"$(__tf_bingo_installed)\" ]; then DEBIAN_FRONTEND=noninteractive apt-get remove -y %s; fi", aptPkg)
+		zypper = fmt.Sprintf("if [ -n \"$(__tf_bingo_installed)\" ]; then zypper -n remove %s; fi", pkg)
+	case packageStateLatest:
+		yum = fmt.Sprintf("$__tf_bingo_pm install -y %[1]s && $__tf_bingo_pm upgrade -y %[1]s", pkg)
+		apt = fmt.Sprintf("apt-get update -q && DEBIAN_FRONTEND=noninteractive apt-get install -y %s", aptPkg)
+		zypper = fmt.Sprintf("zypper -n install %[1]s && zypper -n update %[1]s", pkg)
+	default:
+		yum = fmt.Sprintf("$__tf_bingo_pm install -y %[1]s || $__tf_bingo_pm downgrade -y %[1]s", pkg)
+		apt = fmt.Sprintf("DEBIAN_FRONTEND=noninteractive apt-get install -y --allow-downgrades %s", aptPkg)
+		zypper = fmt.Sprintf("zypper -n install --oldpackage %s", pkg)
+		if version != "" {
+			zypper = fmt.Sprintf("zypper -n install --oldpackage %s", shellQuote(name+"="+version))
+		}
+	}
+
+	return fmt.Sprintf("case \"$__tf_bingo_pm\" in\napt) %s ;;\nzypper) %s ;;\n*) %s ;;\nesac\n", apt, zypper, yum)
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestPackageResource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testPackageResourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_package.dev", "name", "nginx"),
+					resource.TestCheckResourceAttrSet("bingo_cmp_package.dev", "instance_versions.c0dea473-cfc0-49a7-830e-a7edc8f1125d"),
+				),
+			},
+		},
+	})
+}
+
+func TestPackageSatisfied(t *testing.T) {
+	for _, c := range []struct {
+		state, version, installed, latest string
+		want                              bool
+	}{
+		{"present", "", "1.20.1-1.el7", "", true},
+		{"present", "", "", "", false},
+		{"present", "1.20.1", "1.20.1-1.el7", "", true},
+		{"present", "1.20.1", "1:1.20.1-1.el7", "", true},
+		{"present", "1.20.2", "1.20.1-1.el7", "", false},
+		{"present", "1.20", "1.20.1-1.el7", "", false},
+		{"absent", "", "1.20.1-1.el7", "", false},
+		{"absent", "", "", "", true},
+		{"latest", "", "1.20.1-1.el7", "1.22.0-1.el7", false},
+		{"latest", "", "1.22.0-1.el7", "1.22.0-1.el7", true},
+		{"latest", "", "1.22.0-1.el7", "", true},
+		{"latest", "", "", "", false},
+	} {
+		if got := packageSatisfied(c.state, c.version, c.installed, c.latest); got != c.want {
+			t.Errorf("packageSatisfied(%q, %q, %q, %q) = %v, want %v", c.state, c.version, c.installed, c.latest, got, c.want)
+		}
+	}
+}
+
+func TestPackageApplyScript(t *testing.T) {
+	d := mapGetter{"name": "nginx", "version": "1.20.1", "state": "present", "package_manager": "auto"}
+	script := packageApplyScript(d)
+	for _, want := range []string{
+		"for __tf_bingo_pm in dnf yum apt-get zypper none",
+		"$__tf_bingo_pm install -y 'nginx-1.20.1'",
+		"apt-get install -y --allow-downgrades 'nginx=1.20.1'",
+		"echo \"__TF_BINGO__version=$(__tf_bingo_installed)\"",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("apply script does not contain %q:\n%s", want, script)
+		}
+	}
+
+	d["state"], d["package_manager"] = "latest", "apt"
+	script = packageApplyScript(d)
+	if !strings.Contains(script, "__tf_bingo_pm=apt\n") || strings.Contains(script, "1.20.1") {
+		t.Errorf("unexpected latest script:\n%s", script)
+	}
+	if !strings.Contains(packageReadScript(d), "apt-cache policy 'nginx'") {
+		t.Errorf("read script does not query the latest version")
+	}
+}
+
+func testPackageResourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_package" "dev" {
+  host_type    = "1"
+  instance_ids = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  name         = "nginx"
+}
+`)
+}
@@ -0,0 +1,333 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+// The CMP gateway does not expose a scheduling API, scheduled commands are installed as cron.d entries.
+const (
+	cronEntryDir  = "/etc/cron.d"
+	cronScriptDir = "/etc/terraform-bingo/cron"
+)
+
+var (
+	cronFieldRegexp    = regexp.MustCompile(`^[0-9A-Za-z*/,\-]+$`)
+	cronMacroRegexp    = regexp.MustCompile(`^@(yearly|annually|monthly|weekly|daily|midnight|hourly)$`)
+	timeZoneRegexp     = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_+\-]*(/[A-Za-z0-9_+\-]+)*$`)
+	cronUserNameRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_.\-]*$`)
+)
+
+func resourceCmpScheduledCommand() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "定时指令（仅支持Linux），通过CMP指令在实例上安装受管理的cron.d任务，" +
+			"读取时校验各实例上的任务以发现漂移，并返回各实例最近一次执行的状态。" +
+			"任务由实例上的cron执行而非CMP下发，CMP中没有对应的指令记录，因此执行状态取自任务在实例上写入的状态文件，而非`DescribeCommand`",
+
+		CreateContext: resourceCmpScheduledCommandCreate,
+		ReadContext:   resourceCmpScheduledCommandRead,
+		UpdateContext: resourceCmpScheduledCommandUpdate,
+		DeleteContext: resourceCmpScheduledCommandDelete,
+
+		CustomizeDiff: resourceCmpScheduledCommandCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Read:   schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"host_type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.HostTypeVirtualMachine, cmp.HostTypePhysical}, false),
+				Description:  "宿主机类型，1:虚拟机,2:物理机",
+			},
+			"instance_ids": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "实例编号，多个用逗号分割",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "任务名称",
+			},
+			"schedule": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateCronExpression,
+				Description:  "cron表达式，如`0 2 * * *`，也支持`@daily`等简写",
+			},
+			"time_zone": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringMatch(timeZoneRegexp, "must be an IANA time zone such as Asia/Shanghai"),
+				Description:  "cron表达式的时区，如`Asia/Shanghai`，为空时使用实例的时区，需要实例的cron支持`CRON_TZ`",
+			},
+			"user": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "root",
+				ValidateFunc: validation.StringMatch(cronUserNameRegexp, "must be a valid user name"),
+				Description:  "执行任务的用户",
+			},
+			"content": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "命令内容",
+			},
+			"enabled": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "是否启用，停用时保留任务但不再执行",
+			},
+			"instance_checksums": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "各实例上任务的SHA256，任务不存在时为空",
+			},
+			"last_run_status": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "各实例最近一次执行的状态，success:成功,failed:失败，未执行过时不包含该实例，取自实例上的状态文件",
+			},
+			"last_run_time": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "各实例最近一次执行的结束时间",
+			},
+		},
+	}
+}
+
+func validateCronExpression(v interface{}, k string) (ws []string, errs []error) {
+	expr := strings.TrimSpace(v.(string))
+	if cronMacroRegexp.MatchString(expr) {
+		return
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		errs = append(errs, fmt.Errorf("%q must have 5 fields or be a macro such as @daily, got: %s", k, expr))
+		return
+	}
+	for _, field := range fields {
+		if !cronFieldRegexp.MatchString(field) {
+			errs = append(errs, fmt.Errorf("%q has an invalid field %q", k, field))
+		}
+	}
+	return
+}
+
+func resourceCmpScheduledCommandCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	// The ID is part of the entry, it is only known once created.
+	if d.Id() == "" {
+		return nil
+	}
+	for _, key := range []string{"instance_ids", "name", "schedule", "time_zone", "user", "content", "enabled"} {
+		if !d.NewValueKnown(key) {
+			return d.SetNewComputed("instance_checksums")
+		}
+	}
+	checksum := cronChecksum(d.Id(), d)
+	expected := map[string]interface{}{}
+	for _, id := range splitInstanceIds(d.Get("instance_ids").(string)) {
+		expected[id] = checksum
+	}
+	return d.SetNew("instance_checksums", expected)
+}
+
+func resourceCmpScheduledCommandCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	// Set the ID first, a partly installed entry is tainted and removed by the next apply.
+	d.SetId(resource.UniqueId())
+
+	instanceIds := splitInstanceIds(d.Get("instance_ids").(string))
+	if err := runOnAll(ctx, client, d.Get("host_type").(string), instanceIds, "terraform-cron-install", cronInstallScript(d.Id(), d), d.Timeout(schema.TimeoutCreate)); err != nil {
+		return diagErrorf("[CMP] Unable to install scheduled command %s, got error: %s", d.Get("name"), err)
+	}
+
+	checksums := map[string]interface{}{}
+	for _, id := range instanceIds {
+		checksums[id] = cronChecksum(d.Id(), d)
+	}
+	d.Set("instance_checksums", checksums)
+	d.Set("last_run_status", map[string]interface{}{})
+	d.Set("last_run_time", map[string]interface{}{})
+
+	return nil
+}
+
+func resourceCmpScheduledCommandRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	results, err := runCommand(ctx, client, &commandRun{
+		HostType:    d.Get("host_type").(string),
+		InstanceIds: splitInstanceIds(d.Get("instance_ids").(string)),
+		Name:        "terraform-cron-read",
+		Content:     cronReadScript(d.Id()),
+		Timeout:     d.Timeout(schema.TimeoutRead),
+	})
+	if err != nil {
+		return diagErrorf("[CMP] Unable to read scheduled command %s, got error: %s", d.Get("name"), err)
+	}
+
+	return observeInstances(d, results, []string{"instance_checksums", "last_run_status", "last_run_time"},
+		fmt.Sprintf("scheduled command %s", d.Get("name")),
+		func(result *commandResult) (map[string]string, bool) {
+			checksum, ok := result.Marker("checksum")
+			if !result.Succeeded() || !ok {
+				return nil, false
+			}
+			values := map[string]string{"instance_checksums": checksum}
+			if v, ok := result.Marker("last_run"); ok {
+				if status, at, ok := parseCronStatus(v); ok {
+					values["last_run_status"], values["last_run_time"] = status, formatTime(at)
+				}
+			}
+			return values, true
+		})
+}
+
+func resourceCmpScheduledCommandUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	hostType := d.Get("host_type").(string)
+	checksum := cronChecksum(d.Id(), d)
+	instanceIds, removed, targets := updateTargets(d, []string{"name", "schedule", "time_zone", "user", "content", "enabled"},
+		priorDrift(d, "instance_checksums", checksum))
+	if len(removed) > 0 {
+		if err := runOnAll(ctx, client, hostType, removed, "terraform-cron-delete", cronRemoveScript(d.Id()), d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return diagErrorf("[CMP] Unable to remove scheduled command %s, got error: %s", d.Get("name"), err)
+		}
+	}
+	if len(targets) > 0 {
+		if err := runOnAll(ctx, client, hostType, targets, "terraform-cron-install", cronInstallScript(d.Id(), d), d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return diagErrorf("[CMP] Unable to install scheduled command %s, got error: %s", d.Get("name"), err)
+		}
+	}
+
+	checksums := map[string]interface{}{}
+	for _, id := range instanceIds {
+		checksums[id] = checksum
+	}
+	d.Set("instance_checksums", checksums)
+
+	tflog.Debug(ctx, "[CMP] Updated a scheduled command successfully", map[string]interface{}{
+		"name":    d.Get("name"),
+		"targets": targets,
+	})
+
+	return nil
+}
+
+func resourceCmpScheduledCommandDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	instanceIds := splitInstanceIds(d.Get("instance_ids").(string))
+	if err := runOnAll(ctx, client, d.Get("host_type").(string), instanceIds, "terraform-cron-delete", cronRemoveScript(d.Id()), d.Timeout(schema.TimeoutDelete)); err != nil {
+		return diagErrorf("[CMP] Unable to remove scheduled command %s, got error: %s", d.Get("name"), err)
+	}
+	return nil
+}
+
+func cronPaths(id string) (entry, script, status string) {
+	entry = fmt.Sprintf("%s/terraform-bingo-%s", cronEntryDir, id)
+	script = fmt.Sprintf("%s/%s.sh", cronScriptDir, id)
+	status = fmt.Sprintf("%s/%s.status", cronScriptDir, id)
+	return
+}
+
+// cronEntry renders the cron.d file, the job records its exit code and end time for Read to report.
+func cronEntry(id string, d resourceGetter) string {
+	_, script, status := cronPaths(id)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Managed by terraform-provider-bingo: %s\n", d.Get("name"))
+	if tz := d.Get("time_zone").(string); tz != "" {
+		fmt.Fprintf(&b, "CRON_TZ=%s\n", tz)
+	}
+	if !d.Get("enabled").(bool) {
+		b.WriteString("# ")
+	}
+	// `%` is special in crontab and must be escaped.
+	fmt.Fprintf(&b, "%s %s /bin/sh %s >/dev/null 2>&1; rc=$?; echo \"$rc $(date +\\%%s)\" > %s\n",
+		strings.TrimSpace(d.Get("schedule").(string)), d.Get("user"), script, status)
+	return b.String()
+}
+
+func cronChecksum(id string, d resourceGetter) string {
+	return sha256Hex([]byte(cronEntry(id, d) + d.Get("content").(string)))
+}
+
+func cronInstallScript(id string, d resourceGetter) string {
+	entry, script, _ := cronPaths(id)
+
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	fmt.Fprintf(&b, "mkdir -p %s\n", cronScriptDir)
+	fmt.Fprintf(&b, "printf '%%s' %s | base64 -d > %s\n", base64.StdEncoding.EncodeToString([]byte(d.Get("content").(string))), script)
+	fmt.Fprintf(&b, "printf '%%s' %s | base64 -d > %s.tmp\n", base64.StdEncoding.EncodeToString([]byte(cronEntry(id, d))), entry)
+	fmt.Fprintf(&b, "chmod 0644 %s.tmp\n", entry)
+	fmt.Fprintf(&b, "mv -f %s.tmp %s\n", entry, entry)
+	return b.String()
+}
+
+// cronReadScript prints the checksum of the installed entry and script, matching cronChecksum, and the last run.
+func cronReadScript(id string) string {
+	entry, script, status := cronPaths(id)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "if [ -f %s ] && [ -f %s ]; then %s; else %s; fi\n", entry, script,
+		scriptMarker("checksum", fmt.Sprintf("$(cat %s %s | sha256sum | cut -d' ' -f1)", entry, script)), scriptMarker("checksum", ""))
+	fmt.Fprintf(&b, "if [ -f %s ]; then %s; fi\n", status, scriptMarker("last_run", fmt.Sprintf("$(cat %s)", status)))
+	return b.String()
+}
+
+func cronRemoveScript(id string) string {
+	entry, script, status := cronPaths(id)
+	return fmt.Sprintf("rm -f %s %s %s", entry, script, status)
+}
+
+// parseCronStatus parses the `<exit code> <unix time>` line written by the job.
+func parseCronStatus(s string) (string, time.Time, bool) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return "", time.Time{}, false
+	}
+	rc, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	status := cmp.CommandStatusSuccess
+	if rc != 0 {
+		status = cmp.CommandStatusFailed
+	}
+	return status, time.Unix(sec, 0), true
+}
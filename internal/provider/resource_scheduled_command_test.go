@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestScheduledCommandResource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testScheduledCommandResourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_scheduled_command.dev", "schedule", "0 2 * * *"),
+					resource.TestCheckResourceAttrSet("bingo_cmp_scheduled_command.dev", "instance_checksums.c0dea473-cfc0-49a7-830e-a7edc8f1125d"),
+				),
+			},
+		},
+	})
+}
+
+func TestValidateCronExpression(t *testing.T) {
+	for expr, valid := range map[string]bool{
+		"0 2 * * *":          true,
+		"*/5 0-6 1,15 * MON": true,
+		"@daily":             true,
+		"@sometimes":         false,
+		"0 2 * *":            false,
+		"0 2 * * * /bin/rm":  false,
+		"0 2 * * ;":          false,
+	} {
+		_, errs := validateCronExpression(expr, "schedule")
+		if (len(errs) == 0) != valid {
+			t.Errorf("validateCronExpression(%q) = %v, want valid %v", expr, errs, valid)
+		}
+	}
+}
+
+func TestCronEntry(t *testing.T) {
+	d := mapGetter{"name": "cleanup", "schedule": " 0 2 * * * ", "user": "root", "time_zone": "Asia/Shanghai", "enabled": false}
+	entry := cronEntry("abc", d)
+
+	for _, want := range []string{
+		"CRON_TZ=Asia/Shanghai\n",
+		"# 0 2 * * * root /bin/sh /etc/terraform-bingo/cron/abc.sh",
+		`$(date +\%s)`,
+		"> /etc/terraform-bingo/cron/abc.status\n",
+	} {
+		if !strings.Contains(entry, want) {
+			t.Errorf("cron entry does not contain %q:\n%s", want, entry)
+		}
+	}
+
+	d["enabled"] = true
+	if strings.Contains(cronEntry("abc", d), "# 0 2") {
+		t.Errorf("enabled entry is commented out")
+	}
+}
+
+func TestParseCronStatus(t *testing.T) {
+	status, at, ok := parseCronStatus("0 1650000000")
+	if !ok || status != "success" || at.Unix() != 1650000000 {
+		t.Errorf("unexpected status %s at %s", status, at)
+	}
+	if status, _, _ := parseCronStatus("2 1650000000"); status != "failed" {
+		t.Errorf("unexpected status %s", status)
+	}
+	if _, _, ok := parseCronStatus("garbage"); ok {
+		t.Errorf("expected garbage to be rejected")
+	}
+}
+
+func testScheduledCommandResourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_scheduled_command" "dev" {
+  host_type    = "1"
+  instance_ids = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  name         = "cleanup"
+  schedule     = "0 2 * * *"
+  time_zone    = "Asia/Shanghai"
+  content      = "find /tmp -mtime +7 -delete"
+}
+`)
+}
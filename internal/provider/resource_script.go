@@ -0,0 +1,282 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+	"terraform-provider-bingo/utils"
+)
+
+const scriptInterpreterShell = "sh"
+
+var scriptInterpreters = []string{scriptInterpreterShell, "bash", "python", "python3", "perl"}
+
+func resourceCmpScript() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "CMP脚本库中的脚本，修改内容、解释器、参数或描述时创建新版本，已有版本不会被修改",
+
+		CreateContext: resourceCmpScriptCreate,
+		ReadContext:   resourceCmpScriptRead,
+		UpdateContext: resourceCmpScriptUpdate,
+		DeleteContext: resourceCmpScriptDelete,
+
+		CustomizeDiff: resourceCmpScriptCustomizeDiff,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "脚本名称",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "脚本描述",
+			},
+			"interpreter": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      scriptInterpreterShell,
+				ValidateFunc: validation.StringInSlice(scriptInterpreters, false),
+				Description:  "解释器，可选值：" + strings.Join(scriptInterpreters, ","),
+			},
+			"content": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "脚本内容",
+			},
+			"params": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "脚本参数，执行时以同名环境变量传入",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringMatch(envNameRegexp, "must be a valid environment variable name"),
+							Description:  "参数名称",
+						},
+						"description": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "参数描述",
+						},
+						"default": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "默认值",
+						},
+						"required": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "是否必填，必填且没有默认值的参数执行时必须传入",
+						},
+					},
+				},
+			},
+			"version": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "最新版本号",
+			},
+		},
+	}
+}
+
+// scriptVersionKeys are the attributes stored with each version, changing any of them creates a new version.
+var scriptVersionKeys = []string{"description", "interpreter", "content", "params"}
+
+func resourceCmpScriptCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() != "" && d.HasChanges(scriptVersionKeys...) {
+		return d.SetNewComputed("version")
+	}
+	return nil
+}
+
+func resourceCmpScriptCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	input := &cmp.ScriptInput{
+		Name:        d.Get("name").(string),
+		Description: d.Get("description").(string),
+		Interpreter: d.Get("interpreter").(string),
+		Content:     d.Get("content").(string),
+		Params:      expandScriptParams(d.Get("params").([]interface{})),
+	}
+
+	output, err := client.cmpClient.CreateScript(ctx, input)
+	if err != nil {
+		return diagErrorf("[CMP] Unable to create script %s, got error: %s", input.Name, err)
+	}
+
+	d.SetId(output.Id)
+	d.Set("version", output.Version)
+
+	tflog.Debug(ctx, "[CMP] Created a script successfully", utils.RedactFields(map[string]interface{}{
+		"id":      output.Id,
+		"version": output.Version,
+	}))
+
+	return resourceCmpScriptRead(ctx, d, meta)
+}
+
+func resourceCmpScriptRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	output, err := client.cmpClient.DescribeScript(ctx, d.Id(), 0)
+	if err != nil {
+		return diagErrorf("[CMP] Unable to read script, got error: %s", err)
+	}
+	if output == nil {
+		tflog.Warn(ctx, "[CMP] Script not found, removing from state", map[string]interface{}{"id": d.Id()})
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("name", output.Name)
+	d.Set("description", output.Description)
+	if output.Interpreter == "" {
+		output.Interpreter = scriptInterpreterShell
+	}
+	d.Set("interpreter", output.Interpreter)
+	d.Set("content", output.Content)
+	d.Set("version", output.Version)
+	if err := d.Set("params", flattenScriptParams(output.Params)); err != nil {
+		return diagErrorf("[CMP] Unable to set params: %s", err)
+	}
+
+	return nil
+}
+
+func resourceCmpScriptUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	if d.HasChanges(scriptVersionKeys...) {
+		output, err := client.cmpClient.CreateScriptVersion(ctx, &cmp.ScriptVersionInput{
+			ScriptId:    d.Id(),
+			Description: d.Get("description").(string),
+			Interpreter: d.Get("interpreter").(string),
+			Content:     d.Get("content").(string),
+			Params:      expandScriptParams(d.Get("params").([]interface{})),
+		})
+		if err != nil {
+			return diagErrorf("[CMP] Unable to create a version of script %s, got error: %s", d.Get("name"), err)
+		}
+		d.Set("version", output.Version)
+
+		tflog.Debug(ctx, "[CMP] Created a script version successfully", map[string]interface{}{
+			"id":      d.Id(),
+			"version": output.Version,
+		})
+	}
+
+	return resourceCmpScriptRead(ctx, d, meta)
+}
+
+func resourceCmpScriptDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	if err := client.cmpClient.DeleteScript(ctx, d.Id()); err != nil {
+		return diagErrorf("[CMP] Unable to delete script %s, got error: %s", d.Get("name"), err)
+	}
+
+	tflog.Debug(ctx, "[CMP] Deleted a script successfully", map[string]interface{}{"id": d.Id()})
+	return nil
+}
+
+func expandScriptParams(list []interface{}) []*cmp.ScriptParam {
+	params := make([]*cmp.ScriptParam, 0, len(list))
+	for _, item := range list {
+		m := item.(map[string]interface{})
+		params = append(params, &cmp.ScriptParam{
+			Name:        m["name"].(string),
+			Description: m["description"].(string),
+			Default:     m["default"].(string),
+			Required:    m["required"].(bool),
+		})
+	}
+	return params
+}
+
+func flattenScriptParams(params []*cmp.ScriptParam) []interface{} {
+	list := make([]interface{}, 0, len(params))
+	for _, param := range params {
+		list = append(list, map[string]interface{}{
+			"name":        param.Name,
+			"description": param.Description,
+			"default":     param.Default,
+			"required":    param.Required,
+		})
+	}
+	return list
+}
+
+// renderScript builds the command running a library script version, exporting the params as
+// environment variables after applying the defaults and checking them against the declared ones.
+func renderScript(script *cmp.ScriptOutput, values map[string]interface{}) (string, error) {
+	declared := map[string]*cmp.ScriptParam{}
+	for _, param := range script.Params {
+		declared[param.Name] = param
+	}
+
+	env := map[string]string{}
+	for name, v := range values {
+		if _, ok := declared[name]; !ok {
+			return "", fmt.Errorf("script %s version %d has no param %q", script.Name, script.Version, name)
+		}
+		env[name] = v.(string)
+	}
+	for _, param := range script.Params {
+		if _, ok := env[param.Name]; ok {
+			continue
+		}
+		if param.Default != "" {
+			env[param.Name] = param.Default
+		} else if param.Required {
+			return "", fmt.Errorf("script %s version %d requires param %q", script.Name, script.Version, param.Name)
+		}
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "export %s=%s\n", name, shellQuote(env[name]))
+	}
+
+	interpreter := script.Interpreter
+	if interpreter == "" || interpreter == scriptInterpreterShell {
+		b.WriteString(script.Content)
+		return b.String(), nil
+	}
+
+	// Other interpreters run the script from a temporary file, the exit code is the script's.
+	b.WriteString("__tf_bingo_script=$(mktemp)\n")
+	fmt.Fprintf(&b, "printf '%%s' %s | base64 -d > \"$__tf_bingo_script\"\n", base64.StdEncoding.EncodeToString([]byte(script.Content)))
+	fmt.Fprintf(&b, "%s \"$__tf_bingo_script\"\n", interpreter)
+	b.WriteString("__tf_bingo_rc=$?\n")
+	b.WriteString("rm -f \"$__tf_bingo_script\"\n")
+	b.WriteString("exit $__tf_bingo_rc\n")
+	return b.String(), nil
+}
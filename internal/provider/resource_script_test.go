@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+func TestScriptResource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testScriptResourceConfig("echo \"hello $NAME\""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_script.dev", "version", "1"),
+					resource.TestCheckResourceAttr("bingo_cmp_command.dev", "script_version", "1"),
+				),
+			},
+			{
+				Config: testScriptResourceConfig("echo \"hi $NAME\""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_script.dev", "version", "2"),
+				),
+			},
+		},
+	})
+}
+
+func TestRenderScript(t *testing.T) {
+	script := &cmp.ScriptOutput{
+		Name:    "greet",
+		Version: 3,
+		Content: "echo \"$GREETING, $NAME\"",
+		Params: []*cmp.ScriptParam{
+			{Name: "GREETING", Default: "hello"},
+			{Name: "NAME", Required: true},
+		},
+	}
+
+	for _, interpreter := range []string{"", "sh", "bash"} {
+		if _, err := exec.LookPath("bash"); err != nil && interpreter == "bash" {
+			continue
+		}
+		script.Interpreter = interpreter
+		content, err := renderScript(script, map[string]interface{}{"NAME": "it's me"})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		output, err := exec.Command("sh", "-c", content).CombinedOutput()
+		if err != nil || strings.TrimSpace(string(output)) != "hello, it's me" {
+			t.Fatalf("interpreter %q: unexpected output %q: %v", interpreter, output, err)
+		}
+	}
+
+	if _, err := renderScript(script, nil); err == nil || !strings.Contains(err.Error(), `"NAME"`) {
+		t.Errorf("expected a missing required param error, got %v", err)
+	}
+	if _, err := renderScript(script, map[string]interface{}{"NAME": "x", "OTHER": "y"}); err == nil || !strings.Contains(err.Error(), `"OTHER"`) {
+		t.Errorf("expected an unknown param error, got %v", err)
+	}
+}
+
+func TestRenderScriptExitCode(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not available")
+	}
+	content, err := renderScript(&cmp.ScriptOutput{Interpreter: "bash", Content: "exit 3"}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = exec.Command("sh", "-c", content).Run()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+		t.Fatalf("expected exit code 3, got %v", err)
+	}
+}
+
+func testScriptResourceConfig(content string) string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_script" "dev" {
+  name    = "terraform-greet"
+  content = %q
+
+  params {
+    name    = "NAME"
+    default = "world"
+  }
+}
+
+resource "bingo_cmp_command" "dev" {
+  host_type    = "1"
+  instance_ids = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  script_id    = bingo_cmp_script.dev.id
+  params = {
+    NAME = "terraform"
+  }
+}
+`, content)
+}
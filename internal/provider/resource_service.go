@@ -0,0 +1,345 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+const (
+	serviceStateRunning = "running"
+	serviceStateStopped = "stopped"
+)
+
+var serviceNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.@:-]+$`)
+
+func resourceCmpService() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "通过CMP指令管理实例上的服务，Linux按实例识别systemd或SysV init，Windows使用服务管理器。" +
+			"读取时查询各实例上服务的运行及开机启动状态以发现漂移，删除时仅从状态中移除，不停止服务",
+
+		CreateContext: resourceCmpServiceCreate,
+		ReadContext:   resourceCmpServiceRead,
+		UpdateContext: resourceCmpServiceUpdate,
+		DeleteContext: resourceCmpServiceDelete,
+
+		CustomizeDiff: resourceCmpServiceCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Read:   schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"host_type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{cmp.HostTypeVirtualMachine, cmp.HostTypePhysical}, false),
+				Description:  "宿主机类型，1:虚拟机,2:物理机",
+			},
+			"instance_ids": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "实例编号，多个用逗号分割",
+			},
+			"name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringMatch(serviceNameRegexp, "must be a service name"),
+				Description:  "服务名称，如`nginx`",
+			},
+			"enabled": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "是否开机启动，Windows上为自动或手动启动",
+			},
+			"state": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      serviceStateRunning,
+				ValidateFunc: validation.StringInSlice([]string{serviceStateRunning, serviceStateStopped}, false),
+				Description:  "期望状态，running:运行,stopped:停止",
+			},
+			"triggers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "任意值变化时在全部实例上重启服务，如配置文件的校验和，`state`为`stopped`时不重启",
+			},
+			"instance_states": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "各实例上服务的状态，running:运行,stopped:停止",
+			},
+			"instance_enabled": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "各实例上服务是否开机启动，true或false",
+			},
+		},
+	}
+}
+
+func resourceCmpServiceCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("instance_ids") || !d.NewValueKnown("state") || !d.NewValueKnown("enabled") {
+		if err := d.SetNewComputed("instance_states"); err != nil {
+			return err
+		}
+		return d.SetNewComputed("instance_enabled")
+	}
+
+	states, enabled := map[string]interface{}{}, map[string]interface{}{}
+	for _, id := range splitInstanceIds(d.Get("instance_ids").(string)) {
+		states[id] = d.Get("state").(string)
+		enabled[id] = strconv.FormatBool(d.Get("enabled").(bool))
+	}
+	if err := d.SetNew("instance_states", states); err != nil {
+		return err
+	}
+	return d.SetNew("instance_enabled", enabled)
+}
+
+func resourceCmpServiceCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	if err := applyService(ctx, client, d, splitInstanceIds(d.Get("instance_ids").(string)), false, d.Timeout(schema.TimeoutCreate)); err != nil {
+		return diagErrorf("[CMP] Unable to apply service %s, got error: %s", d.Get("name"), err)
+	}
+
+	d.SetId(resource.UniqueId())
+
+	return nil
+}
+
+func resourceCmpServiceRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	results, err := runServiceScript(ctx, client, d, splitInstanceIds(d.Get("instance_ids").(string)),
+		"terraform-service-read", serviceReadScript, d.Timeout(schema.TimeoutRead))
+	if err != nil {
+		return diagErrorf("[CMP] Unable to read service %s, got error: %s", d.Get("name"), err)
+	}
+
+	return observeInstances(d, results, []string{"instance_states", "instance_enabled"}, fmt.Sprintf("service %s", d.Get("name")),
+		func(result *commandResult) (map[string]string, bool) {
+			state, ok := result.Marker("state")
+			enabled, _ := result.Marker("enabled")
+			return map[string]string{"instance_states": state, "instance_enabled": enabled}, result.Succeeded() && ok
+		})
+}
+
+func resourceCmpServiceUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*bingoCloudClient)
+
+	restart := d.HasChange("triggers") && d.Get("state").(string) == serviceStateRunning
+
+	// Only converge the instances which drifted, unless the desired status or the triggers changed.
+	// The service is left as is on the instances which are no longer targeted.
+	_, _, targets := updateTargets(d, []string{"enabled", "state", "triggers"}, func(instanceIds []string) []string {
+		drifted := map[string]bool{}
+		for _, id := range priorDrift(d, "instance_states", d.Get("state").(string))(instanceIds) {
+			drifted[id] = true
+		}
+		for _, id := range priorDrift(d, "instance_enabled", strconv.FormatBool(d.Get("enabled").(bool)))(instanceIds) {
+			drifted[id] = true
+		}
+		var targets []string
+		for _, id := range instanceIds {
+			if drifted[id] {
+				targets = append(targets, id)
+			}
+		}
+		return targets
+	})
+
+	if len(targets) > 0 {
+		if err := applyService(ctx, client, d, targets, restart, d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return diagErrorf("[CMP] Unable to apply service %s, got error: %s", d.Get("name"), err)
+		}
+	}
+
+	tflog.Debug(ctx, "[CMP] Updated a service successfully", map[string]interface{}{
+		"name":    d.Get("name"),
+		"targets": targets,
+		"restart": restart,
+	})
+
+	return nil
+}
+
+func resourceCmpServiceDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// The service is left as it is, it was not created by this resource.
+	d.SetId("")
+	return nil
+}
+
+// applyService converges the service on the instances and records the status they report afterwards.
+func applyService(ctx context.Context, client *bingoCloudClient, d *schema.ResourceData, instanceIds []string, restart bool, timeout time.Duration) error {
+	results, err := runServiceScript(ctx, client, d, instanceIds, "terraform-service-apply", func(family string, d resourceGetter) string {
+		return serviceApplyScript(family, d, restart)
+	}, timeout)
+	if err != nil {
+		return err
+	}
+	if err := failedResults(results); err != nil {
+		return err
+	}
+
+	states := map[string]interface{}{}
+	enabled := map[string]interface{}{}
+	for _, id := range splitInstanceIds(d.Get("instance_ids").(string)) {
+		copyMapValue(states, d.Get("instance_states").(map[string]interface{}), id)
+		copyMapValue(enabled, d.Get("instance_enabled").(map[string]interface{}), id)
+	}
+	for id, result := range results {
+		states[id], _ = result.Marker("state")
+		enabled[id], _ = result.Marker("enabled")
+	}
+	d.Set("instance_states", states)
+	d.Set("instance_enabled", enabled)
+
+	return nil
+}
+
+// runServiceScript runs the script rendered for the OS family of each instance.
+func runServiceScript(ctx context.Context, client *bingoCloudClient, d *schema.ResourceData, instanceIds []string, name string,
+	script func(family string, d resourceGetter) string, timeout time.Duration) (map[string]*commandResult, error) {
+	hostType := d.Get("host_type").(string)
+	groups, err := groupInstancesByOsFamily(ctx, client, hostType, instanceIds)
+	if err != nil {
+		return nil, err
+	}
+
+	results := map[string]*commandResult{}
+	for family, ids := range groups {
+		groupResults, err := runCommand(ctx, client, &commandRun{
+			HostType:    hostType,
+			InstanceIds: ids,
+			Name:        name,
+			Content:     script(family, d),
+			Timeout:     timeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for id, result := range groupResults {
+			results[id] = result
+		}
+	}
+	return results, nil
+}
+
+// serviceReadScript prints the `state` and `enabled` markers of the service.
+func serviceReadScript(family string, d resourceGetter) string {
+	if family == osFamilyWindows {
+		return windowsServicePrelude(d) + windowsServiceMarkers
+	}
+	return linuxServicePrelude(d) + linuxServiceMarkers
+}
+
+// serviceApplyScript enables or disables, then starts, restarts or stops the service and prints its status.
+func serviceApplyScript(family string, d resourceGetter, restart bool) string {
+	enabled := d.Get("enabled").(bool)
+	running := d.Get("state").(string) == serviceStateRunning
+
+	var b strings.Builder
+	if family == osFamilyWindows {
+		b.WriteString("$ErrorActionPreference = 'Stop'\n")
+		b.WriteString(windowsServicePrelude(d))
+		if enabled {
+			b.WriteString("Set-Service -Name $name -StartupType Automatic\n")
+		} else {
+			b.WriteString("Set-Service -Name $name -StartupType Manual\n")
+		}
+		switch {
+		case running && restart:
+			b.WriteString("Restart-Service -Name $name -Force\n")
+		case running:
+			b.WriteString("Start-Service -Name $name\n")
+		default:
+			b.WriteString("Stop-Service -Name $name -Force\n")
+		}
+		b.WriteString("$svc = Get-Service -Name $name\n")
+		b.WriteString(windowsServiceMarkers)
+		return b.String()
+	}
+
+	b.WriteString("set -e\n")
+	b.WriteString(linuxServicePrelude(d))
+	b.WriteString("if [ \"$__tf_bingo_init\" = systemd ]; then\n")
+	if enabled {
+		b.WriteString("  systemctl enable \"$__tf_bingo_service\"\n")
+	} else {
+		b.WriteString("  systemctl disable \"$__tf_bingo_service\"\n")
+	}
+	switch {
+	case running && restart:
+		b.WriteString("  systemctl restart \"$__tf_bingo_service\"\n")
+	case running:
+		b.WriteString("  systemctl start \"$__tf_bingo_service\"\n")
+	default:
+		b.WriteString("  systemctl stop \"$__tf_bingo_service\"\n")
+	}
+	b.WriteString("else\n")
+	if enabled {
+		b.WriteString("  chkconfig \"$__tf_bingo_service\" on 2>/dev/null || update-rc.d \"$__tf_bingo_service\" enable\n")
+	} else {
+		b.WriteString("  chkconfig \"$__tf_bingo_service\" off 2>/dev/null || update-rc.d \"$__tf_bingo_service\" disable\n")
+	}
+	switch {
+	case running && restart:
+		b.WriteString("  service \"$__tf_bingo_service\" restart\n")
+	case running:
+		b.WriteString("  service \"$__tf_bingo_service\" status >/dev/null 2>&1 || service \"$__tf_bingo_service\" start\n")
+	default:
+		b.WriteString("  ! service \"$__tf_bingo_service\" status >/dev/null 2>&1 || service \"$__tf_bingo_service\" stop\n")
+	}
+	b.WriteString("fi\n")
+	b.WriteString(linuxServiceMarkers)
+	return b.String()
+}
+
+// linuxServicePrelude detects the init system, systemd when it is running as PID 1, otherwise SysV init.
+func linuxServicePrelude(d resourceGetter) string {
+	return fmt.Sprintf("__tf_bingo_service=%s\n", shellQuote(d.Get("name").(string))) +
+		"if command -v systemctl >/dev/null 2>&1 && [ -d /run/systemd/system ]; then __tf_bingo_init=systemd; else __tf_bingo_init=sysv; fi\n"
+}
+
+const linuxServiceMarkers = `if [ "$__tf_bingo_init" = systemd ]; then
+  systemctl is-active --quiet "$__tf_bingo_service" && __tf_bingo_state=running || __tf_bingo_state=stopped
+  systemctl is-enabled --quiet "$__tf_bingo_service" 2>/dev/null && __tf_bingo_enabled=true || __tf_bingo_enabled=false
+else
+  service "$__tf_bingo_service" status >/dev/null 2>&1 && __tf_bingo_state=running || __tf_bingo_state=stopped
+  ls /etc/rc[2345].d/S[0-9][0-9]"$__tf_bingo_service" >/dev/null 2>&1 && __tf_bingo_enabled=true || __tf_bingo_enabled=false
+fi
+` + `echo "` + markerPrefix + `init=$__tf_bingo_init"
+` + `echo "` + markerPrefix + `state=$__tf_bingo_state"
+` + `echo "` + markerPrefix + `enabled=$__tf_bingo_enabled"
+`
+
+func windowsServicePrelude(d resourceGetter) string {
+	return fmt.Sprintf("$name = %s\n$svc = Get-Service -Name $name -ErrorAction Stop\n", powershellQuote(d.Get("name").(string)))
+}
+
+const windowsServiceMarkers = `Write-Output '` + markerPrefix + `init=windows'
+Write-Output ('` + markerPrefix + `state=' + $(if ($svc.Status -eq 'Running') { 'running' } else { 'stopped' }))
+Write-Output ('` + markerPrefix + `enabled=' + $(if ([string]$svc.StartType -eq 'Automatic') { 'true' } else { 'false' }))
+`
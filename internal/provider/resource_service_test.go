@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestServiceResource(t *testing.T) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testServiceResourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("bingo_cmp_service.dev", "instance_states.c0dea473-cfc0-49a7-830e-a7edc8f1125d", "running"),
+					resource.TestCheckResourceAttr("bingo_cmp_service.dev", "instance_enabled.c0dea473-cfc0-49a7-830e-a7edc8f1125d", "true"),
+				),
+			},
+		},
+	})
+}
+
+func TestServiceApplyScript(t *testing.T) {
+	d := mapGetter{"name": "nginx", "enabled": true, "state": "running"}
+
+	script := serviceApplyScript(osFamilyLinux, d, true)
+	for _, want := range []string{
+		"__tf_bingo_service='nginx'\n",
+		"systemctl enable \"$__tf_bingo_service\"",
+		"systemctl restart \"$__tf_bingo_service\"",
+		"service \"$__tf_bingo_service\" restart",
+		"echo \"__TF_BINGO__state=$__tf_bingo_state\"",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("linux script does not contain %q:\n%s", want, script)
+		}
+	}
+
+	d["enabled"], d["state"] = false, "stopped"
+	script = serviceApplyScript(osFamilyWindows, d, false)
+	for _, want := range []string{
+		"$name = 'nginx'\n",
+		"Set-Service -Name $name -StartupType Manual",
+		"Stop-Service -Name $name -Force",
+		"'__TF_BINGO__enabled='",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("windows script does not contain %q:\n%s", want, script)
+		}
+	}
+}
+
+func testServiceResourceConfig() string {
+	return fmt.Sprintf(`
+provider "bingo" {
+
+}
+
+resource "bingo_cmp_service" "dev" {
+  host_type    = "1"
+  instance_ids = "c0dea473-cfc0-49a7-830e-a7edc8f1125d"
+  name         = "nginx"
+
+  triggers = {
+    config = "v1"
+  }
+}
+`)
+}
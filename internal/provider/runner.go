@@ -0,0 +1,251 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+	"terraform-provider-bingo/utils"
+)
+
+// runnerPollInterval is how often runCommand polls, generated scripts are short so it is
+// more eager than the 20 seconds used for user commands.
+const runnerPollInterval = 5 * time.Second
+
+// commandRun describes a script sent by a resource on behalf of the user.
+type commandRun struct {
+	HostType    string
+	InstanceIds []string
+	Name        string
+	Content     string
+	Timeout     time.Duration
+}
+
+// commandResult is the outcome of a command on one instance.
+type commandResult struct {
+	InstanceId string
+	RecordId   string
+	TaskId     string
+	Status     string
+	Log        string
+}
+
+// Succeeded reports whether the step of the instance succeeded.
+func (its *commandResult) Succeeded() bool {
+	return its.Status == cmp.CommandStatusSuccess
+}
+
+// Marker returns the value printed by the script as a `key=value` line, see scriptMarker.
+func (its *commandResult) Marker(key string) (string, bool) {
+	prefix := markerPrefix + key + "="
+	lines := strings.Split(its.Log, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimPrefix(line, prefix), true
+		}
+	}
+	return "", false
+}
+
+// markerPrefix prefixes the lines a generated script prints for the provider to parse.
+const markerPrefix = "__TF_BINGO__"
+
+// scriptMarker returns the shell statement printing value as the marker key, value is a shell expression.
+func scriptMarker(key, value string) string {
+	return fmt.Sprintf(`echo "%s%s=%s"`, markerPrefix, key, value)
+}
+
+// runCommand sends the script to the instances, holding their locks until it ends, and returns the result
+// of every instance. A failed command is not an error, the results of the failed instances report it.
+func runCommand(ctx context.Context, client *bingoCloudClient, run *commandRun) (map[string]*commandResult, error) {
+	instanceIds := strings.Join(run.InstanceIds, ",")
+
+	unlock, err := client.locker.Lock(ctx, run.InstanceIds)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for instances (%s): %s", instanceIds, err)
+	}
+	defer unlock()
+
+	input := &cmp.CommandInput{
+		Name:        run.Name + "-" + time.Now().Format("20060102150405"),
+		Content:     run.Content,
+		HostType:    run.HostType,
+		InstanceIds: instanceIds,
+		Description: "Created by `terraform-provider-bingo`",
+	}
+	output, err := client.cmpClient.CreateCommand(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	stateConf := &resource.StateChangeConf{
+		Pending:      []string{cmp.CommandStatusNew, cmp.CommandStatusDeploying},
+		Target:       []string{cmp.CommandStatusSuccess, cmp.CommandStatusFailed},
+		Refresh:      refreshCommandRecord(ctx, client, output.RecordId),
+		Timeout:      run.Timeout,
+		PollInterval: runnerPollInterval,
+	}
+	if _, err = stateConf.WaitForStateContext(ctx); err != nil {
+		return nil, fmt.Errorf("waiting for command (%s): %s", output.RecordId, err)
+	}
+
+	steps, err := client.cmpClient.DescribeAllCommandSteps(ctx, &cmp.DescribeCommandStepsInput{
+		SqlId:  "task.listAllStepsForAgent",
+		ConStr: "deploy",
+		Params: struct {
+			TaskId string `json:"taskId"`
+		}{TaskId: output.TaskId},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := map[string]*commandResult{}
+	for _, step := range steps {
+		id := stepInstanceId(step, run.InstanceIds)
+		if id == "" {
+			continue
+		}
+		results[id] = &commandResult{InstanceId: id, RecordId: output.RecordId, TaskId: output.TaskId, Status: step.StepStatus, Log: step.StepLog}
+	}
+	for _, id := range run.InstanceIds {
+		if _, ok := results[id]; !ok {
+			results[id] = &commandResult{InstanceId: id, RecordId: output.RecordId, TaskId: output.TaskId, Status: cmp.CommandStatusFailed, Log: "command step not found"}
+		}
+	}
+
+	tflog.Debug(ctx, "[CMP] Ran a command successfully", utils.RedactFields(map[string]interface{}{
+		"name":      input.Name,
+		"record_id": output.RecordId,
+		"task_id":   output.TaskId,
+	}))
+
+	return results, nil
+}
+
+// stepInstanceId returns which of instanceIds the step ran on.
+func stepInstanceId(step *cmp.DescribeCommandStepsOutput, instanceIds []string) string {
+	for _, id := range instanceIds {
+		if step.MachineId == id || step.InstanceCode == id || step.MachineCode == id {
+			return id
+		}
+	}
+	return ""
+}
+
+// failedResults returns an error listing the instances on which the command failed, if any.
+func failedResults(results map[string]*commandResult) error {
+	var failed []string
+	for id, result := range results {
+		if !result.Succeeded() {
+			log, _ := tailLog(utils.Redact(result.Log), 10, 0)
+			failed = append(failed, fmt.Sprintf("%s: %s", id, log))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	sort.Strings(failed)
+	return fmt.Errorf("command failed on %d instance(s):\n%s", len(failed), strings.Join(failed, "\n"))
+}
+
+// observeInstances sets the per-instance map attributes keys from the results of a read script, observe returns
+// the values of one instance by attribute and may leave out the attributes it has no value for. An instance which
+// cannot be read keeps its last known values with a warning, an unreachable host is not proof of drift.
+func observeInstances(d *schema.ResourceData, results map[string]*commandResult, keys []string, what string,
+	observe func(result *commandResult) (map[string]string, bool)) diag.Diagnostics {
+	var diags diag.Diagnostics
+	observed := make(map[string]map[string]interface{}, len(keys))
+	for _, key := range keys {
+		observed[key] = map[string]interface{}{}
+	}
+	for id, result := range results {
+		values, ok := observe(result)
+		if !ok {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("[CMP] Unable to read %s on instance %s", what, id),
+			})
+			for _, key := range keys {
+				copyMapValue(observed[key], d.Get(key).(map[string]interface{}), id)
+			}
+			continue
+		}
+		for key, v := range values {
+			observed[key][id] = v
+		}
+	}
+	for _, key := range keys {
+		d.Set(key, observed[key])
+	}
+	return diags
+}
+
+// updateTargets returns the instances targeted after the update, those which are no longer targeted and must be
+// cleaned up, and those to converge: every targeted instance when one of the desired attributes changed,
+// otherwise only the ones drifted reports.
+func updateTargets(d *schema.ResourceData, desired []string, drifted func(instanceIds []string) []string) (instanceIds, removed, targets []string) {
+	oldIds, newIds := d.GetChange("instance_ids")
+	instanceIds = splitInstanceIds(newIds.(string))
+	removed = subtractIds(splitInstanceIds(oldIds.(string)), instanceIds)
+	targets = instanceIds
+	if !d.HasChanges(desired...) {
+		targets = drifted(instanceIds)
+	}
+	return instanceIds, removed, targets
+}
+
+// priorDrift returns the instances whose value of the per-instance map attribute key, as observed before
+// the update, is not expected.
+func priorDrift(d *schema.ResourceData, key, expected string) func(instanceIds []string) []string {
+	return func(instanceIds []string) []string {
+		observed, _ := d.GetChange(key)
+		return driftedIds(instanceIds, observed.(map[string]interface{}), expected)
+	}
+}
+
+// driftedIds returns the instances whose observed value is not expected.
+func driftedIds(instanceIds []string, observed map[string]interface{}, expected string) []string {
+	var drifted []string
+	for _, id := range instanceIds {
+		if v, ok := observed[id]; !ok || v.(string) != expected {
+			drifted = append(drifted, id)
+		}
+	}
+	return drifted
+}
+
+// subtractIds returns the ids of a which are not in b.
+func subtractIds(a, b []string) []string {
+	set := map[string]bool{}
+	for _, id := range b {
+		set[id] = true
+	}
+	var result []string
+	for _, id := range a {
+		if !set[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func copyMapValue(dst, src map[string]interface{}, key string) {
+	if v, ok := src[key]; ok {
+		dst[key] = v
+	}
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
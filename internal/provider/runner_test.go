@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"terraform-provider-bingo/internal/pkg/cmp"
+)
+
+func TestObserveInstances(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceCmpFile().Schema, map[string]interface{}{
+		"host_type":    "1",
+		"instance_ids": "vm-1,vm-2,vm-3",
+		"path":         "/etc/terraform-bingo.conf",
+		"content":      "key = value\n",
+	})
+	d.Set("instance_checksums", map[string]interface{}{"vm-1": "old", "vm-2": "old"})
+
+	results := map[string]*commandResult{
+		"vm-1": {Status: cmp.CommandStatusSuccess, Log: markerPrefix + "checksum=new"},
+		"vm-2": {Status: cmp.CommandStatusFailed, Log: "agent offline"},
+		"vm-3": {Status: cmp.CommandStatusFailed, Log: "agent offline"},
+	}
+	diags := observeInstances(d, results, []string{"instance_checksums"}, "file", func(result *commandResult) (map[string]string, bool) {
+		checksum, ok := result.Marker("checksum")
+		return map[string]string{"instance_checksums": checksum}, result.Succeeded() && ok
+	})
+
+	if len(diags) != 2 || diags.HasError() {
+		t.Errorf("expected a warning per unreadable instance, got %v", diags)
+	}
+	// The unreadable instances keep their last known value, if any.
+	if got := fmt.Sprint(d.Get("instance_checksums")); got != "map[vm-1:new vm-2:old]" {
+		t.Errorf("unexpected checksums %s", got)
+	}
+}
+
+func TestDriftedIds(t *testing.T) {
+	observed := map[string]interface{}{"vm-1": "a", "vm-2": "b"}
+	if got := fmt.Sprint(driftedIds([]string{"vm-1", "vm-2", "vm-3"}, observed, "a")); got != "[vm-2 vm-3]" {
+		t.Errorf("unexpected drifted instances %s", got)
+	}
+	if got := fmt.Sprint(subtractIds([]string{"vm-1", "vm-2", "vm-3"}, []string{"vm-2"})); got != "[vm-1 vm-3]" {
+		t.Errorf("unexpected removed instances %s", got)
+	}
+}
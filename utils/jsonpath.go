@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// JSONPath extracts a value from the JSON document doc with a JSONPath-like expression.
+//
+// Supported are the root `$`, member access `.name` or `['name']` and array indexes `[0]`,
+// e.g. `$.data.items[0].id`. The leading `$` is optional. Numbers are returned as json.Number, so
+// large numeric IDs keep every digit.
+func JSONPath(doc []byte, expr string) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid character after top-level value")
+	}
+
+	tokens, err := parseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, token := range tokens {
+		switch current := v.(type) {
+		case map[string]interface{}:
+			val, ok := current[token]
+			if !ok {
+				return nil, fmt.Errorf("%q: key %q not found", expr, token)
+			}
+			v = val
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %q is not an index", expr, token)
+			}
+			if i < 0 {
+				i += len(current)
+			}
+			if i < 0 || i >= len(current) {
+				return nil, fmt.Errorf("%q: index %d out of range", expr, i)
+			}
+			v = current[i]
+		default:
+			return nil, fmt.Errorf("%q: can not select %q from %T", expr, token, v)
+		}
+	}
+
+	return v, nil
+}
+
+// JSONPathString is like JSONPath but returns strings as is and any other value as JSON.
+func JSONPathString(doc []byte, expr string) (string, error) {
+	v, err := JSONPath(doc, expr)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func parseJSONPath(expr string) ([]string, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+
+	var tokens []string
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("%q: empty member name", expr)
+			}
+			tokens = append(tokens, s[:end])
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%q: missing ]", expr)
+			}
+			token := strings.TrimSpace(s[1:end])
+			if len(token) >= 2 && (token[0] == '\'' || token[0] == '"') && token[len(token)-1] == token[0] {
+				token = token[1 : len(token)-1]
+			}
+			tokens = append(tokens, token)
+			s = s[end+1:]
+		default:
+			// A path without the leading `$.`, e.g. `data.id`.
+			if len(tokens) > 0 {
+				return nil, fmt.Errorf("%q: unexpected %q", expr, s[0])
+			}
+			s = "." + s
+		}
+	}
+
+	return tokens, nil
+}
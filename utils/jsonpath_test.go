@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestJSONPathString(t *testing.T) {
+	doc := []byte(`{"data":{"id":"r1","items":[{"name":"a"},{"name":"b"}],"total":2,"tag.name":"x"}}`)
+
+	cases := map[string]string{
+		"$.data.id":             "r1",
+		"data.id":               "r1",
+		"$.data.items[1].name":  "b",
+		"$.data.items[-1].name": "b",
+		"$.data.total":          "2",
+		"$['data']['tag.name']": "x",
+		"$.data.items[0]":       `{"name":"a"}`,
+	}
+	for expr, want := range cases {
+		got, err := JSONPathString(doc, expr)
+		if err != nil || got != want {
+			t.Errorf("JSONPathString(%q) = %q, %v, want %q", expr, got, err, want)
+		}
+	}
+
+	for _, expr := range []string{"$.data.missing", "$.data.items[5]", "$.data.id.x", "$.data[", "$..id"} {
+		if _, err := JSONPathString(doc, expr); err == nil {
+			t.Errorf("JSONPathString(%q) expected error", expr)
+		}
+	}
+}
+
+func TestJSONPathStringLargeNumber(t *testing.T) {
+	// 2^53 + 1 can not be represented by a float64.
+	doc := []byte(`{"data":{"id":9007199254740993,"ids":[1234567890123456789],"ratio":0.5}}`)
+
+	cases := map[string]string{
+		"$.data.id":    "9007199254740993",
+		"$.data.ids":   "[1234567890123456789]",
+		"$.data.ratio": "0.5",
+	}
+	for expr, want := range cases {
+		got, err := JSONPathString(doc, expr)
+		if err != nil || got != want {
+			t.Errorf("JSONPathString(%q) = %q, %v, want %q", expr, got, err, want)
+		}
+	}
+
+	if _, err := JSONPathString([]byte(`{"id":1} trailing`), "$.id"); err == nil {
+		t.Error("expected an error for trailing data")
+	}
+}
@@ -2,112 +2,365 @@ package utils
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-// Prettify returns the string representation of a value.
+const (
+	// DefaultMaxDepth is the nesting depth Prettify descends to when PrettifyOptions.MaxDepth is zero.
+	DefaultMaxDepth = 10
+	// DefaultMaxLength is the number of elements of a slice or map, and of runes of a string,
+	// Prettify prints when PrettifyOptions.MaxLength is zero.
+	DefaultMaxLength = 4096
+)
+
+// Redactor is implemented by values which know how to print themselves without leaking secrets.
+type Redactor interface {
+	Redacted() string
+}
+
+// PrettifyOptions controls the output of PrettifyWith.
+type PrettifyOptions struct {
+	// Compact prints the value on a single line.
+	Compact bool
+	// JSON prints the value as a JSON document, implies Compact.
+	JSON bool
+	// MaxDepth caps the nesting depth, zero means DefaultMaxDepth and a negative value means unlimited.
+	MaxDepth int
+	// MaxLength caps the elements of slices and maps and the runes of strings,
+	// zero means DefaultMaxLength and a negative value means unlimited.
+	MaxLength int
+}
+
+// Prettify returns the string representation of a value, with registered secrets redacted.
+//
+// Struct fields tagged `sensitive:"true"` and values implementing Redactor are never printed verbatim,
+// map keys are sorted so the output is stable.
 func Prettify(i interface{}) string {
-	var buf bytes.Buffer
-	prettify(reflect.ValueOf(i), 0, &buf)
-	return buf.String()
+	return PrettifyWith(i, PrettifyOptions{})
+}
+
+// PrettifyCompact is like Prettify but prints the value on a single line.
+func PrettifyCompact(i interface{}) string {
+	return PrettifyWith(i, PrettifyOptions{Compact: true})
+}
+
+// PrettifyJSON is like Prettify but prints the value as a single line JSON document.
+func PrettifyJSON(i interface{}) string {
+	return PrettifyWith(i, PrettifyOptions{JSON: true})
+}
+
+// PrettifyWith returns the string representation of a value according to opts.
+func PrettifyWith(i interface{}, opts PrettifyOptions) string {
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
+	if opts.MaxLength == 0 {
+		opts.MaxLength = DefaultMaxLength
+	}
+	if opts.JSON {
+		opts.Compact = true
+	}
+
+	// Strings are redacted as they are printed, once quoted a secret containing escaped characters no longer
+	// matches. The output is redacted again in case a secret spans several values.
+	p := &printer{opts: opts, visited: map[uintptr]bool{}}
+	p.prettify(reflect.ValueOf(i), 0, 0)
+	return Redact(p.buf.String())
+}
+
+type printer struct {
+	buf     bytes.Buffer
+	opts    PrettifyOptions
+	visited map[uintptr]bool
 }
 
 // prettify will recursively walk value v to build a textual
 // representation of the value.
-func prettify(v reflect.Value, indent int, buf *bytes.Buffer) {
-	for v.Kind() == reflect.Ptr {
+func (p *printer) prettify(v reflect.Value, indent, depth int) {
+	if p.redactor(v) {
+		return
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			p.marker("<nil>")
+			return
+		}
+		if v.Kind() == reflect.Ptr {
+			if p.visited[v.Pointer()] {
+				p.marker("<cycle>")
+				return
+			}
+			p.visited[v.Pointer()] = true
+			defer delete(p.visited, v.Pointer())
+		}
 		v = v.Elem()
+		if p.redactor(v) {
+			return
+		}
+	}
+
+	if p.opts.MaxDepth > 0 && depth > p.opts.MaxDepth {
+		switch v.Kind() {
+		case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+			p.marker("<max depth>")
+			return
+		}
 	}
 
 	switch v.Kind() {
 	case reflect.Struct:
 		strtype := v.Type().String()
 		if strtype == "time.Time" {
-			fmt.Fprintf(buf, "%s", v.Interface())
+			p.scalar(fmt.Sprintf("%s", v.Interface()), p.opts.JSON)
 			break
 		} else if strings.HasPrefix(strtype, "io.") {
-			buf.WriteString("<buffer>")
+			p.marker("<buffer>")
 			break
 		}
 
-		buf.WriteString("{\n")
+		type field struct {
+			name      string
+			sensitive bool
+		}
 
-		names := []string{}
+		fields := []field{}
 		for i := 0; i < v.Type().NumField(); i++ {
-			name := v.Type().Field(i).Name
+			sf := v.Type().Field(i)
 			f := v.Field(i)
-			if name[0:1] == strings.ToLower(name[0:1]) {
+			if sf.Name[0:1] == strings.ToLower(sf.Name[0:1]) {
 				continue // ignore unexported fields
 			}
 			if (f.Kind() == reflect.Ptr || f.Kind() == reflect.Slice || f.Kind() == reflect.Map) && f.IsNil() {
 				continue // ignore unset fields
 			}
-			names = append(names, name)
+			fields = append(fields, field{name: sf.Name, sensitive: sf.Tag.Get("sensitive") == "true"})
 		}
 
-		for i, n := range names {
-			val := v.FieldByName(n)
-			buf.WriteString(strings.Repeat(" ", indent+2))
-			buf.WriteString(n + ": ")
-			prettify(val, indent+2, buf)
-
-			if i < len(names)-1 {
-				buf.WriteString(",\n")
+		p.open("{", len(fields) > 0)
+		for i, f := range fields {
+			p.key(f.name, indent)
+			if f.sensitive {
+				p.marker(RedactedValue)
+			} else {
+				p.prettify(v.FieldByName(f.name), indent+2, depth+1)
 			}
+			p.separator(i, len(fields))
+		}
+		p.close("}", indent, len(fields) > 0)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			p.marker("<nil>")
+			break
 		}
-
-		buf.WriteString("\n" + strings.Repeat(" ", indent) + "}")
-	case reflect.Slice:
 		strtype := v.Type().String()
 		if strtype == "[]uint8" {
-			fmt.Fprintf(buf, "<binary> len %d", v.Len())
+			p.marker(fmt.Sprintf("<binary> len %d", v.Len()))
 			break
 		}
+		if v.Kind() == reflect.Slice {
+			if p.visited[v.Pointer()] {
+				p.marker("<cycle>")
+				break
+			}
+			if v.Len() > 0 {
+				p.visited[v.Pointer()] = true
+				defer delete(p.visited, v.Pointer())
+			}
+		}
 
+		n := p.limit(v.Len())
+		multiline := !p.opts.Compact && v.Len() > 3
 		nl, id, id2 := "", "", ""
-		if v.Len() > 3 {
+		if multiline {
 			nl, id, id2 = "\n", strings.Repeat(" ", indent), strings.Repeat(" ", indent+2)
 		}
-		buf.WriteString("[" + nl)
-		for i := 0; i < v.Len(); i++ {
-			buf.WriteString(id2)
-			prettify(v.Index(i), indent+2, buf)
+		sep := ","
+		if multiline {
+			sep = ",\n"
+		} else if p.opts.Compact && !p.opts.JSON {
+			sep = ", "
+		}
+
+		p.buf.WriteString("[" + nl)
+		for i := 0; i < n; i++ {
+			p.buf.WriteString(id2)
+			p.prettify(v.Index(i), indent+2, depth+1)
 
 			if i < v.Len()-1 {
-				buf.WriteString("," + nl)
+				p.buf.WriteString(sep)
 			}
 		}
+		if n < v.Len() {
+			p.buf.WriteString(id2)
+			p.marker(fmt.Sprintf("<%d more>", v.Len()-n))
+		}
 
-		buf.WriteString(nl + id + "]")
+		p.buf.WriteString(nl + id + "]")
 	case reflect.Map:
-		buf.WriteString("{\n")
-
-		for i, k := range v.MapKeys() {
-			buf.WriteString(strings.Repeat(" ", indent+2))
-			buf.WriteString(k.String() + ": ")
-			prettify(v.MapIndex(k), indent+2, buf)
+		if v.IsNil() {
+			p.marker("<nil>")
+			break
+		}
+		if p.visited[v.Pointer()] {
+			p.marker("<cycle>")
+			break
+		}
+		p.visited[v.Pointer()] = true
+		defer delete(p.visited, v.Pointer())
 
-			if i < v.Len()-1 {
-				buf.WriteString(",\n")
-			}
+		type entry struct {
+			name string
+			key  reflect.Value
 		}
+		entries := make([]entry, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			entries = append(entries, entry{name: fmt.Sprint(k.Interface()), key: k})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
 
-		buf.WriteString("\n" + strings.Repeat(" ", indent) + "}")
+		n, total := p.limit(len(entries)), len(entries)
+		if n < total {
+			total = n + 1 // the truncation marker is printed as one more entry
+		}
+		p.open("{", len(entries) > 0)
+		for i, e := range entries[:n] {
+			p.key(e.name, indent)
+			p.prettify(v.MapIndex(e.key), indent+2, depth+1)
+			p.separator(i, total)
+		}
+		if n < len(entries) {
+			p.key("<truncated>", indent)
+			p.marker(fmt.Sprintf("<%d more>", len(entries)-n))
+		}
+		p.close("}", indent, len(entries) > 0)
 	default:
 		if !v.IsValid() {
-			fmt.Fprint(buf, "<invalid value>")
+			p.marker("<invalid value>")
+			return
+		}
+		if !v.CanInterface() {
+			p.marker("<unexported>")
 			return
 		}
-		format := "%v"
-		switch v.Interface().(type) {
+		switch val := v.Interface().(type) {
 		case string:
-			format = "%q"
+			// Redact before truncating, so a secret cut at the limit is still scrubbed.
+			p.scalar(p.truncate(Redact(val)), true)
 		case io.ReadSeeker, io.Reader:
-			format = "buffer(%p)"
+			p.marker(fmt.Sprintf("buffer(%p)", val))
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			p.scalar(fmt.Sprintf("%v", val), false)
+		default:
+			p.scalar(fmt.Sprintf("%v", val), p.opts.JSON)
+		}
+	}
+}
+
+// redactor prints v through the Redactor interface when it is implemented.
+func (p *printer) redactor(v reflect.Value) bool {
+	if !v.IsValid() || !v.CanInterface() {
+		return false
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return false
+	}
+	r, ok := v.Interface().(Redactor)
+	if !ok {
+		return false
+	}
+	p.scalar(r.Redacted(), true)
+	return true
+}
+
+// scalar prints a leaf value, quoting it if it is a string. Registered secrets are redacted from the raw value.
+func (p *printer) scalar(s string, quote bool) {
+	s = Redact(s)
+	switch {
+	case !quote:
+		p.buf.WriteString(s)
+	case p.opts.JSON:
+		b, _ := json.Marshal(s)
+		p.buf.Write(b)
+	default:
+		p.buf.WriteString(strconv.Quote(s))
+	}
+}
+
+// marker prints a placeholder such as `<nil>`, which must stay a valid value in JSON mode.
+func (p *printer) marker(s string) {
+	if p.opts.JSON {
+		if s == "<nil>" || s == "<invalid value>" {
+			p.buf.WriteString("null")
+			return
 		}
-		fmt.Fprintf(buf, format, v.Interface())
+		p.scalar(s, true)
+		return
+	}
+	p.buf.WriteString(s)
+}
+
+func (p *printer) open(s string, nonEmpty bool) {
+	p.buf.WriteString(s)
+	if !p.opts.Compact && nonEmpty {
+		p.buf.WriteString("\n")
+	}
+}
+
+func (p *printer) close(s string, indent int, nonEmpty bool) {
+	if !p.opts.Compact && nonEmpty {
+		p.buf.WriteString("\n" + strings.Repeat(" ", indent))
+	}
+	p.buf.WriteString(s)
+}
+
+func (p *printer) key(name string, indent int) {
+	switch {
+	case p.opts.JSON:
+		p.scalar(name, true)
+		p.buf.WriteString(":")
+	case p.opts.Compact:
+		p.buf.WriteString(name + ": ")
+	default:
+		p.buf.WriteString(strings.Repeat(" ", indent+2))
+		p.buf.WriteString(name + ": ")
+	}
+}
+
+func (p *printer) separator(i, n int) {
+	if i >= n-1 {
+		return
+	}
+	switch {
+	case p.opts.JSON:
+		p.buf.WriteString(",")
+	case p.opts.Compact:
+		p.buf.WriteString(", ")
+	default:
+		p.buf.WriteString(",\n")
+	}
+}
+
+// limit returns how many of n elements may be printed.
+func (p *printer) limit(n int) int {
+	if p.opts.MaxLength > 0 && n > p.opts.MaxLength {
+		return p.opts.MaxLength
+	}
+	return n
+}
+
+// truncate shortens s to MaxLength runes.
+func (p *printer) truncate(s string) string {
+	if p.opts.MaxLength <= 0 || utf8.RuneCountInString(s) <= p.opts.MaxLength {
+		return s
 	}
+	runes := []rune(s)
+	return fmt.Sprintf("%s...<%d more>", string(runes[:p.opts.MaxLength]), len(runes)-p.opts.MaxLength)
 }
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type prettifyToken struct {
+	Name   string
+	Secret string `sensitive:"true"`
+	Labels map[string]string
+	Next   *prettifyToken
+}
+
+type prettifyPassword string
+
+func (prettifyPassword) Redacted() string {
+	return "<password>"
+}
+
+func TestPrettify(t *testing.T) {
+	v := prettifyToken{
+		Name:   "at",
+		Secret: "s3cr3t-value",
+		Labels: map[string]string{"b": "2", "a": "1", "c": "3"},
+	}
+
+	got := Prettify(v)
+	if strings.Contains(got, "s3cr3t-value") {
+		t.Fatalf("sensitive field leaked: %s", got)
+	}
+	want := "{\n  Name: \"at\",\n  Secret: ***,\n  Labels: {\n    a: \"1\",\n    b: \"2\",\n    c: \"3\"\n  }\n}"
+	if got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := PrettifyCompact(v); got != `{Name: "at", Secret: ***, Labels: {a: "1", b: "2", c: "3"}}` {
+		t.Fatalf("unexpected compact output: %s", got)
+	}
+
+	if got := Prettify(struct{ Password prettifyPassword }{"hunter2"}); strings.Contains(got, "hunter2") {
+		t.Fatalf("redactor ignored: %s", got)
+	}
+}
+
+func TestPrettifyCycleAndLimits(t *testing.T) {
+	v := &prettifyToken{Name: "loop"}
+	v.Next = v
+
+	if got := PrettifyCompact(v); got != `{Name: "loop", Secret: ***, Next: <cycle>}` {
+		t.Fatalf("unexpected cycle output: %s", got)
+	}
+
+	got := PrettifyWith([]int{1, 2, 3, 4, 5}, PrettifyOptions{Compact: true, MaxLength: 2})
+	if got != "[1, 2, <3 more>]" {
+		t.Fatalf("unexpected truncated output: %s", got)
+	}
+
+	deep := &prettifyToken{Name: "0", Next: &prettifyToken{Name: "1", Next: &prettifyToken{Name: "2"}}}
+	got = PrettifyWith(deep, PrettifyOptions{Compact: true, MaxDepth: 1})
+	if got != `{Name: "0", Secret: ***, Next: {Name: "1", Secret: ***, Next: <max depth>}}` {
+		t.Fatalf("unexpected depth output: %s", got)
+	}
+}
+
+func TestPrettifyJSON(t *testing.T) {
+	v := &prettifyToken{Name: "at", Secret: "s3cr3t-value", Labels: map[string]string{"a": "1"}}
+	v.Next = v
+
+	got := PrettifyJSON(v)
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("invalid JSON %s: %s", got, err)
+	}
+	if decoded["Secret"] != RedactedValue || decoded["Next"] != "<cycle>" {
+		t.Fatalf("unexpected JSON output: %s", got)
+	}
+}
+
+func TestPrettifyRedactsEscapedSecrets(t *testing.T) {
+	secrets := []string{"export A='p\"w'\necho top\nsecret", `C:\Users\svc\key`, "a<b>&c"}
+	RegisterSecret(secrets...)
+
+	v := map[string]interface{}{"multiline": secrets[0], "backslash": secrets[1], "html": secrets[2]}
+	for _, opts := range []PrettifyOptions{{}, {Compact: true}, {JSON: true}} {
+		got := PrettifyWith(v, opts)
+		for _, leak := range []string{"echo top", "svc", "a<b>", `a\u003cb`} {
+			if strings.Contains(got, leak) {
+				t.Errorf("%+v leaks %q: %s", opts, leak, got)
+			}
+		}
+		if strings.Count(got, RedactedValue) != 3 {
+			t.Errorf("%+v expected 3 redacted values: %s", opts, got)
+		}
+	}
+
+	// A secret longer than MaxLength is scrubbed before being cut.
+	if got := PrettifyWith(secrets[0], PrettifyOptions{MaxLength: 10}); strings.Contains(got, "export") {
+		t.Errorf("truncated secret leaks: %s", got)
+	}
+}
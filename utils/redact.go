@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RedactedValue is printed in place of every registered secret.
+const RedactedValue = "***"
+
+var secrets = struct {
+	sync.RWMutex
+	values []string
+}{}
+
+// RegisterSecret records values that must never leave the provider in cleartext.
+// Empty values are ignored.
+func RegisterSecret(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+
+	for _, v := range values {
+		if v == "" || containsString(secrets.values, v) {
+			continue
+		}
+		secrets.values = append(secrets.values, v)
+	}
+
+	// Replace the longest secrets first, so a secret containing another one is fully scrubbed.
+	sort.SliceStable(secrets.values, func(i, j int) bool {
+		return len(secrets.values[i]) > len(secrets.values[j])
+	})
+}
+
+// Redact replaces every registered secret in s with RedactedValue.
+func Redact(s string) string {
+	secrets.RLock()
+	defer secrets.RUnlock()
+
+	for _, v := range secrets.values {
+		s = strings.ReplaceAll(s, v, RedactedValue)
+	}
+	return s
+}
+
+// RedactFields returns a copy of fields which is safe to hand to tflog.
+// Strings and composite values are rendered and scrubbed, other scalars are kept as is.
+func RedactFields(fields map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		switch val := v.(type) {
+		case nil, bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+			redacted[k] = val
+		case string:
+			redacted[k] = Redact(val)
+		default:
+			redacted[k] = Redact(fmt.Sprint(val))
+		}
+	}
+	return redacted
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	RegisterSecret("pass@cmp#2019", "", "cmp#2019")
+
+	if got := Redact("password=pass@cmp#2019&user=bingo"); got != "password=***&user=bingo" {
+		t.Fatalf("unexpected redaction: %s", got)
+	}
+	if got := Redact("no secrets here"); got != "no secrets here" {
+		t.Fatalf("unexpected redaction: %s", got)
+	}
+
+	fields := RedactFields(map[string]interface{}{
+		"input": []string{"bingo", "pass@cmp#2019"},
+		"count": 1,
+	})
+	if fields["input"] != "[bingo ***]" || fields["count"] != 1 {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+}